@@ -1,8 +1,14 @@
 package controller
 
 import (
+	"context"
+	"goframe/pkg/confer"
+	"goframe/pkg/mysql"
+	"goframe/pkg/response"
 	"net/http"
+	"time"
 
+	"github.com/HughNian/nmid/pkg/logger"
 	"github.com/gin-gonic/gin"
 )
 
@@ -12,6 +18,7 @@ type result struct {
 	Data    *struct{} `json:"data,omitempty"`
 }
 
+// HealthCheck 存活检查，不检查依赖的服务，数据库故障时不会导致实例被重启
 func HealthCheck(c *gin.Context) {
 	res := result{
 		State:   200,
@@ -20,3 +27,37 @@ func HealthCheck(c *gin.Context) {
 
 	c.JSON(http.StatusOK, res)
 }
+
+// Readiness 就绪检查，开启mysql时检查全部数据源的写库，失败时返回503，详细错误只写入日志
+func Readiness(c *gin.Context) {
+	res := result{
+		State:   200,
+		Message: "success",
+	}
+	if confer.GetGlobalConfig().Mysql.Enabled {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+		if err := mysql.Ping(ctx); err != nil {
+			logger.Errorf("readiness check error: %v", err)
+			res.State, res.Message = http.StatusServiceUnavailable, "unavailable"
+			c.JSON(http.StatusServiceUnavailable, res)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// SQLStats 数据源连接池状态及按sql指纹的查询统计
+func SQLStats(c *gin.Context) {
+	response.UtilResponseReturnJsonSuccess(c, gin.H{
+		"datasources": mysql.Stats(),
+		"queries":     mysql.QueryStats(),
+	})
+}
+
+// ResetSQLStats 清空查询统计
+func ResetSQLStats(c *gin.Context) {
+	mysql.ResetQueryStats()
+	response.UtilResponseReturnJsonSuccess(c, nil)
+}
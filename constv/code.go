@@ -2,13 +2,21 @@ package constv
 
 const (
 	//common
-	CODE_SUCCESS_OK                = 0
-	CODE_COMMON_OK                 = 1001
-	CODE_COMMON_ACCESS_FAIL        = 1002
-	CODE_COMMON_SERVER_BUSY        = 1003
-	CODE_COMMON_PARAMS_INCOMPLETE  = 1004
-	CODE_COMMON_USER_NO_LOGIN      = 1005
-	CODE_COMMON_DATA_NOT_EXIST     = 1006
-	CODE_COMMON_DATA_ALREADY_EXIST = 1007
-	CODE_VICTORIA_METRICS_ERR      = 1008
+	CODE_SUCCESS_OK                    = 0
+	CODE_COMMON_OK                     = 1001
+	CODE_COMMON_ACCESS_FAIL            = 1002
+	CODE_COMMON_SERVER_BUSY            = 1003
+	CODE_COMMON_PARAMS_INCOMPLETE      = 1004
+	CODE_COMMON_USER_NO_LOGIN          = 1005
+	CODE_COMMON_DATA_NOT_EXIST         = 1006
+	CODE_COMMON_DATA_ALREADY_EXIST     = 1007
+	CODE_VICTORIA_METRICS_ERR          = 1008
+	CODE_COMMON_REQUEST_TIMEOUT        = 1009
+	CODE_COMMON_SERVER_OVERLOAD        = 1010
+	CODE_COMMON_BODY_TOO_LARGE         = 1011
+	CODE_COMMON_IDEMPOTENCY_KEY_REUSED = 1012
+	CODE_COMMON_REQUEST_IN_PROGRESS    = 1013
+	CODE_COMMON_DATA_VERSION_CONFLICT  = 1014
+	CODE_COMMON_DB_POOL_EXHAUSTED      = 1015
+	CODE_COMMON_FORBIDDEN              = 1016
 )
@@ -15,6 +15,9 @@ type Server struct {
 	Gzip  Gzip  `mapstructure:"gzip" json:"gzip" yaml:"gzip"`
 	Mysql Mysql `mapstructure:"mysql" json:"mysql" yaml:"mysql"`
 	Log   Log   `mapstructure:"log" json:"log" yaml:"log"`
+	Http  Http  `mapstructure:"http" json:"http" yaml:"http"`
+	// 文件上传
+	Upload Upload `mapstructure:"upload" json:"upload" yaml:"upload"`
 	sync.RWMutex
 }
 
@@ -46,21 +49,101 @@ type Gzip struct {
 }
 
 type Mysql struct {
-	Enabled bool     `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
-	DBName  string   `mapstructure:"dbname" json:"dbName" yaml:"dbname"`
-	Prefix  string   `mapstructure:"prefix" json:"prefix" yaml:"prefix"`
-	Pool    DBPool   `mapstructure:"pool" json:"pool" yaml:"pool"`
-	Write   DBBase   `mapstructure:"write" json:"write" yaml:"write"`
-	Reads   []DBBase `mapstructure:"reads" json:"reads" yaml:"reads"`
+	Enabled bool `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	// 默认数据源
+	MysqlSource `mapstructure:",squash" yaml:",inline"`
+	// 命名数据源，mysql.NewDaoMysql("billing")，未配置的pool使用默认数据源的pool
+	Datasources map[string]MysqlSource `mapstructure:"datasources" json:"datasources" yaml:"datasources"`
+	// 数据库迁移
+	Migrate Migrate `mapstructure:"migrate" json:"migrate" yaml:"migrate"`
+	// sql日志及统计
+	Log MysqlLog `mapstructure:"log" json:"log" yaml:"log"`
+	// 分片表，key为表名(含prefix)
+	Sharding map[string]Sharding `mapstructure:"sharding" json:"sharding" yaml:"sharding"`
+}
+
+// Sharding 一张表的分库分表规则，分片数为 len(Datasources) * Tables，
+// 第i个分片在 Datasources[i/Tables] 的 <表名>_<i%Tables> 表
+type Sharding struct {
+	// 分片键，列名
+	Key string `mapstructure:"key" json:"key" yaml:"key"`
+	// 分片算法 mod|range|hash(一致性哈希)
+	Strategy string `mapstructure:"strategy" json:"strategy" yaml:"strategy"`
+	// 分库，分片所在的数据源，为空时不分库，使用dao的数据源
+	Datasources []string `mapstructure:"datasources" json:"datasources" yaml:"datasources"`
+	// 分表，每个库中的表数量，0或1时不分表
+	Tables int `mapstructure:"tables" json:"tables" yaml:"tables"`
+	// range的分片边界，分片i包含 [ranges[i-1], ranges[i])，数量为分片数-1
+	Ranges []int64 `mapstructure:"ranges" json:"ranges" yaml:"ranges"`
+	// hash每个分片的虚拟节点数，默认160
+	VirtualNodes int `mapstructure:"virtual-nodes" json:"virtualNodes" yaml:"virtual-nodes"`
+	// 允许没有分片键的查询扫描全部分片，也可以单次查询使用mysql.Scatter()
+	AllowScatter bool `mapstructure:"allow-scatter" json:"allowScatter" yaml:"allow-scatter"`
 }
 
+// MysqlLog 慢查询日志、抽样审计及按sql指纹的统计
+type MysqlLog struct {
+	// 慢查询阈值，单位毫秒，0时使用默认200
+	SlowThreshold int `mapstructure:"slow-threshold" json:"slowThreshold" yaml:"slow-threshold"`
+	// 普通查询的抽样比例 0-1，0为不记录
+	SampleRate float64 `mapstructure:"sample-rate" json:"sampleRate" yaml:"sample-rate"`
+	// 按sql指纹统计次数、耗时分位及行数
+	Stats bool `mapstructure:"stats" json:"stats" yaml:"stats"`
+	// 开发环境对查询执行EXPLAIN，提示全表扫描
+	Explain bool `mapstructure:"explain" json:"explain" yaml:"explain"`
+	// 非开发环境开放 /debug/sql/stats，需同时配置AdminToken或AdminAllowIPs
+	Admin bool `mapstructure:"admin" json:"admin" yaml:"admin"`
+	// 访问 /debug/sql/stats 的令牌，请求头 Authorization: Bearer <token>
+	AdminToken string `mapstructure:"admin-token" json:"-" yaml:"admin-token"`
+	// 允许访问 /debug/sql/stats 的直连地址，支持ip及cidr
+	AdminAllowIPs []string `mapstructure:"admin-allow-ips" json:"adminAllowIps" yaml:"admin-allow-ips"`
+}
+
+// Migrate 数据库迁移，命名数据源的迁移文件在Dir下以数据源名称命名的子目录
+type Migrate struct {
+	// 关闭启动时自动迁移，开发环境不自动迁移
+	DisableAuto bool   `mapstructure:"disable-auto" json:"disableAuto" yaml:"disable-auto"`
+	Dir         string `mapstructure:"dir" json:"dir" yaml:"dir"`
+	Table       string `mapstructure:"table" json:"table" yaml:"table"`
+	// 等待其他实例迁移的时间，单位秒
+	LockTimeout int `mapstructure:"lock-timeout" json:"lockTimeout" yaml:"lock-timeout"`
+	// 初始数据目录，子目录common及各环境名称
+	SeedDir string `mapstructure:"seed-dir" json:"seedDir" yaml:"seed-dir"`
+}
+
+// MysqlSource 单个数据源，一个写库及若干读库
+type MysqlSource struct {
+	DBName string   `mapstructure:"dbname" json:"dbName" yaml:"dbname"`
+	Prefix string   `mapstructure:"prefix" json:"prefix" yaml:"prefix"`
+	Pool   DBPool   `mapstructure:"pool" json:"pool" yaml:"pool"`
+	Write  DBBase   `mapstructure:"write" json:"write" yaml:"write"`
+	Reads  []DBBase `mapstructure:"reads" json:"reads" yaml:"reads"`
+	// 读库负载均衡 round-robin|weighted|least-conn
+	ReadBalance string `mapstructure:"read-balance" json:"readBalance" yaml:"read-balance"`
+	// 读库健康检查间隔，单位秒
+	HealthCheckInterval int `mapstructure:"health-check-interval" json:"healthCheckInterval" yaml:"health-check-interval"`
+}
+
+// DBPool 连接池，时间单位均为秒
 type DBPool struct {
-	PoolMinCap      int           `mapstructure:"pool-min-cap" json:"poolMinCap" yaml:"pool-min-cap"`
-	PoolExCap       int           `mapstructure:"pool-ex-cap" json:"poolExCap" yaml:"pool-ex-cap"`
-	PoolMaxCap      int           `mapstructure:"pool-max-cap" json:"pool-max-cap" yaml:"pool-max-cap"`
+	// 常驻的空闲连接，启动及定期ping时补足
+	PoolMinCap int `mapstructure:"pool-min-cap" json:"poolMinCap" yaml:"pool-min-cap"`
+	// 超出PoolMinCap可保留的空闲连接，空闲连接上限为两者之和
+	PoolExCap  int `mapstructure:"pool-ex-cap" json:"poolExCap" yaml:"pool-ex-cap"`
+	PoolMaxCap int `mapstructure:"pool-max-cap" json:"pool-max-cap" yaml:"pool-max-cap"`
+	// 连接空闲超过该时间后关闭
 	PoolIdleTimeout time.Duration `mapstructure:"pool-idle-timeout" json:"poolIdleTimeout" yaml:"pool-idle-timeout"`
+	// 连接的最长使用时间，0不限制
+	PoolMaxLifetime time.Duration `mapstructure:"pool-max-lifetime" json:"poolMaxLifetime" yaml:"pool-max-lifetime"`
+	// 连接全部被占用时最多等待的请求数，超出或等待超时返回mysql.ErrPoolExhausted，0不限制
 	PoolWaitCount   int64         `mapstructure:"pool-wait-count" json:"poolWaitCount" yaml:"pool-wait-count"`
-	PoolWaitTimeout time.Duration `mapstructure:"pool-wai-timeout" json:"poolWaitTimeout" yaml:"pool-wai-timeout"`
+	PoolWaitTimeout time.Duration `mapstructure:"pool-wait-timeout" json:"poolWaitTimeout" yaml:"pool-wait-timeout"`
+	// Deprecated: 拼写错误的旧配置项，未配置pool-wait-timeout时使用
+	PoolWaiTimeout time.Duration `mapstructure:"pool-wai-timeout" json:"-" yaml:"pool-wai-timeout,omitempty"`
+	// 启动时连接失败的重试次数，间隔从1秒开始翻倍，最长30秒
+	PoolConnectRetries int `mapstructure:"pool-connect-retries" json:"poolConnectRetries" yaml:"pool-connect-retries"`
+	// 定期ping写库的间隔，0不ping
+	PoolPingInterval time.Duration `mapstructure:"pool-ping-interval" json:"poolPingInterval" yaml:"pool-ping-interval"`
 }
 
 type DBBase struct {
@@ -68,10 +151,81 @@ type DBBase struct {
 	Port     int    `mapstructure:"port" json:"port" yaml:"port"`
 	User     string `mapstructure:"user" json:"user" yaml:"user"`
 	Password string `mapstructure:"password" json:"password" yaml:"password"`
+	Weight   int    `mapstructure:"weight" json:"weight" yaml:"weight"` // 读库权重，默认1
 	DBName   string `json:"-"`
 	Prefix   string `json:"-"`
 }
 
+// Http http服务配置，时间单位均为秒，0表示不限制
+type Http struct {
+	ReadTimeout       int      `mapstructure:"read-timeout" json:"readTimeout" yaml:"read-timeout"`
+	ReadHeaderTimeout int      `mapstructure:"read-header-timeout" json:"readHeaderTimeout" yaml:"read-header-timeout"`
+	WriteTimeout      int      `mapstructure:"write-timeout" json:"writeTimeout" yaml:"write-timeout"`
+	IdleTimeout       int      `mapstructure:"idle-timeout" json:"idleTimeout" yaml:"idle-timeout"`
+	MaxHeaderBytes    int      `mapstructure:"max-header-bytes" json:"maxHeaderBytes" yaml:"max-header-bytes"`
+	HandlerTimeout    int      `mapstructure:"handler-timeout" json:"handlerTimeout" yaml:"handler-timeout"`
+	MaxBodyBytes      int64    `mapstructure:"max-body-bytes" json:"maxBodyBytes" yaml:"max-body-bytes"`
+	MaxInFlight       int      `mapstructure:"max-in-flight" json:"maxInFlight" yaml:"max-in-flight"`
+	Listen            []string `mapstructure:"listen" json:"listen" yaml:"listen"`
+	RestartTimeout    int      `mapstructure:"restart-timeout" json:"restartTimeout" yaml:"restart-timeout"`
+	OpenAPI           bool     `mapstructure:"openapi" json:"openapi" yaml:"openapi"`
+	// 响应格式 json|msgpack|protobuf|xml，按Accept协商
+	ResponseFormats       []string   `mapstructure:"response-formats" json:"responseFormats" yaml:"response-formats"`
+	ResponseDefaultFormat string     `mapstructure:"response-default-format" json:"responseDefaultFormat" yaml:"response-default-format"`
+	H2C                   bool       `mapstructure:"h2c" json:"h2c" yaml:"h2c"`
+	TLS                   HttpTLS    `mapstructure:"tls" json:"tls" yaml:"tls"`
+	Static                HttpStatic `mapstructure:"static" json:"static" yaml:"static"`
+}
+
+// HttpTLS https配置，Port为0时app.port只提供https，否则明文端口与https端口同时监听
+type HttpTLS struct {
+	Enabled      bool   `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	Port         int    `mapstructure:"port" json:"port" yaml:"port"`
+	CertFile     string `mapstructure:"cert-file" json:"certFile" yaml:"cert-file"`
+	KeyFile      string `mapstructure:"key-file" json:"keyFile" yaml:"key-file"`
+	ClientCAFile string `mapstructure:"client-ca-file" json:"clientCaFile" yaml:"client-ca-file"`
+	ClientAuth   string `mapstructure:"client-auth" json:"clientAuth" yaml:"client-auth"`
+}
+
+// Upload 文件上传及存储配置，大小单位为字节，时间单位为秒
+type Upload struct {
+	Enabled      bool     `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	Storage      string   `mapstructure:"storage" json:"storage" yaml:"storage"`
+	MaxSize      int64    `mapstructure:"max-size" json:"maxSize" yaml:"max-size"`
+	AllowedTypes []string `mapstructure:"allowed-types" json:"allowedTypes" yaml:"allowed-types"`
+	ChunkSize    int64    `mapstructure:"chunk-size" json:"chunkSize" yaml:"chunk-size"`
+	SignSecret   string   `mapstructure:"sign-secret" json:"signSecret" yaml:"sign-secret"`
+	SignExpire   int      `mapstructure:"sign-expire" json:"signExpire" yaml:"sign-expire"`
+	Local        LocalFS  `mapstructure:"local" json:"local" yaml:"local"`
+	S3           S3       `mapstructure:"s3" json:"s3" yaml:"s3"`
+}
+
+type LocalFS struct {
+	Root    string `mapstructure:"root" json:"root" yaml:"root"`
+	BaseURL string `mapstructure:"base-url" json:"baseUrl" yaml:"base-url"`
+}
+
+// S3 兼容S3协议的对象存储，如aws s3、minio、oss
+type S3 struct {
+	Endpoint  string `mapstructure:"endpoint" json:"endpoint" yaml:"endpoint"`
+	Region    string `mapstructure:"region" json:"region" yaml:"region"`
+	Bucket    string `mapstructure:"bucket" json:"bucket" yaml:"bucket"`
+	AccessKey string `mapstructure:"access-key" json:"accessKey" yaml:"access-key"`
+	SecretKey string `mapstructure:"secret-key" json:"secretKey" yaml:"secret-key"`
+	UseSSL    bool   `mapstructure:"use-ssl" json:"useSsl" yaml:"use-ssl"`
+}
+
+// HttpStatic 静态文件及单页应用，Dir为空时使用static.SetEmbedFS注册的嵌入文件
+type HttpStatic struct {
+	Enabled     bool     `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	Dir         string   `mapstructure:"dir" json:"dir" yaml:"dir"`
+	Prefix      string   `mapstructure:"prefix" json:"prefix" yaml:"prefix"`
+	Index       string   `mapstructure:"index" json:"index" yaml:"index"`
+	SPA         bool     `mapstructure:"spa" json:"spa" yaml:"spa"`
+	MaxAge      int      `mapstructure:"max-age" json:"maxAge" yaml:"max-age"`
+	APIPrefixes []string `mapstructure:"api-prefixes" json:"apiPrefixes" yaml:"api-prefixes"`
+}
+
 type Log struct {
 	Enabled bool         `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
 	OutPut  string       `mapstructure:"out-put" json:"outPut" yaml:"out-put"`
@@ -53,35 +53,19 @@ func changeDataByEnv() (err error) {
 	if redis := os.Getenv(globalConfig.Redis.Address); len(redis) > 0 {
 		globalConfig.Redis.Address = redis
 	}
-	if mysqlDbname := os.Getenv(globalConfig.Mysql.DBName); len(mysqlDbname) > 0 {
-		globalConfig.Mysql.DBName = mysqlDbname
-	}
-	if mysqlWriteAddr := os.Getenv(globalConfig.Mysql.Write.Host); len(mysqlWriteAddr) > 0 {
-		globalConfig.Mysql.Write.Host = mysqlWriteAddr
+	if err = changeMysqlByEnv(&globalConfig.Mysql.MysqlSource); err != nil {
+		return
 	}
-
-	// 处理mysql地址
-	var host = "127.0.0.1"
-	var port = "3306"
-	if len(globalConfig.Mysql.Write.Host) > 0 {
-		host, port, err = net.SplitHostPort(globalConfig.Mysql.Write.Host)
-		if err != nil {
-			err = fmt.Errorf("mysql host port is wrong :%w,%s", err, globalConfig.Mysql.Write.Host)
+	for name, source := range globalConfig.Mysql.Datasources {
+		if source.Pool == (DBPool{}) {
+			source.Pool = globalConfig.Mysql.Pool
+		}
+		if err = changeMysqlByEnv(&source); err != nil {
+			err = fmt.Errorf("mysql datasource %s: %w", name, err)
 			return
 		}
+		globalConfig.Mysql.Datasources[name] = source
 	}
-	globalConfig.Mysql.Write.Host = host
-	portInt, _ := strconv.Atoi(port)
-	globalConfig.Mysql.Write.Port = portInt
-
-	if mysqlWriteUser := os.Getenv(globalConfig.Mysql.Write.User); len(mysqlWriteUser) > 0 {
-		globalConfig.Mysql.Write.User = mysqlWriteUser
-	}
-	if mysqlWritePwd := os.Getenv(globalConfig.Mysql.Write.Password); len(mysqlWritePwd) > 0 {
-		globalConfig.Mysql.Write.Password = mysqlWritePwd
-	}
-	globalConfig.Mysql.Write.DBName = globalConfig.Mysql.DBName
-	globalConfig.Mysql.Write.Prefix = globalConfig.Mysql.Prefix
 
 	if logRedisHost := os.Getenv(globalConfig.Log.Redis.Host); len(logRedisHost) > 0 {
 		globalConfig.Log.Redis.Host = logRedisHost
@@ -103,3 +87,72 @@ func GetGlobalConfig() *Server {
 	defer mutex.RUnlock()
 	return globalConfig
 }
+
+// changeMysqlByEnv 数据源的库名、地址及账号支持环境变量，地址支持 "host:port" 格式
+func changeMysqlByEnv(source *MysqlSource) (err error) {
+	if source.Pool.PoolWaitTimeout == 0 {
+		source.Pool.PoolWaitTimeout = source.Pool.PoolWaiTimeout
+	}
+	if mysqlDbname := os.Getenv(source.DBName); len(mysqlDbname) > 0 {
+		source.DBName = mysqlDbname
+	}
+	if mysqlWriteAddr := os.Getenv(source.Write.Host); len(mysqlWriteAddr) > 0 {
+		source.Write.Host = mysqlWriteAddr
+	}
+
+	// 处理mysql地址
+	var host = "127.0.0.1"
+	var port = "3306"
+	if len(source.Write.Host) > 0 {
+		host, port, err = net.SplitHostPort(source.Write.Host)
+		if err != nil {
+			err = fmt.Errorf("mysql host port is wrong :%w,%s", err, source.Write.Host)
+			return
+		}
+	}
+	source.Write.Host = host
+	portInt, _ := strconv.Atoi(port)
+	source.Write.Port = portInt
+
+	if mysqlWriteUser := os.Getenv(source.Write.User); len(mysqlWriteUser) > 0 {
+		source.Write.User = mysqlWriteUser
+	}
+	if mysqlWritePwd := os.Getenv(source.Write.Password); len(mysqlWritePwd) > 0 {
+		source.Write.Password = mysqlWritePwd
+	}
+	source.Write.DBName = source.DBName
+	source.Write.Prefix = source.Prefix
+
+	// 处理读库地址，与写库相同支持环境变量及 "host:port" 格式
+	for i := range source.Reads {
+		read := &source.Reads[i]
+		if readAddr := os.Getenv(read.Host); len(readAddr) > 0 {
+			read.Host = readAddr
+		}
+		if read.Port == 0 {
+			read.Port = portInt
+			if strings.Contains(read.Host, ":") {
+				host, port, err = net.SplitHostPort(read.Host)
+				if err != nil {
+					err = fmt.Errorf("mysql read host port is wrong :%w,%s", err, read.Host)
+					return
+				}
+				read.Host = host
+				read.Port, _ = strconv.Atoi(port)
+			}
+		}
+		if readUser := os.Getenv(read.User); len(readUser) > 0 {
+			read.User = readUser
+		}
+		if readPwd := os.Getenv(read.Password); len(readPwd) > 0 {
+			read.Password = readPwd
+		}
+		if read.User == "" {
+			read.User = source.Write.User
+			read.Password = source.Write.Password
+		}
+		read.DBName = source.DBName
+		read.Prefix = source.Prefix
+	}
+	return
+}
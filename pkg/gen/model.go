@@ -0,0 +1,355 @@
+package gen
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"gorm.io/gorm/schema"
+)
+
+// 生成文件的首行，只有带该首行的文件会被重新生成覆盖
+const generatedHeader = "// Code generated by goframe gen model. DO NOT EDIT."
+
+// 按Go命名习惯全部大写的单词
+var initialisms = map[string]bool{
+	"id": true, "ip": true, "url": true, "uri": true, "uid": true, "uuid": true, "md5": true,
+	"api": true, "json": true, "html": true, "http": true, "sql": true, "sku": true,
+}
+
+// mysql.Model包含的列
+var modelColumns = map[string]bool{"id": true, "created_at": true, "updated_at": true, "deleted_at": true}
+
+// Options 生成选项
+type Options struct {
+	Dir        string // 输出目录，默认./app
+	Module     string // app下的模块，默认base
+	ModulePath string // go.mod中的module，默认读取当前目录的go.mod
+	Prefix     string // 表前缀，结构体名称不含前缀
+	Datasource string // 生成的Repository使用的数据源
+	CRUD       bool   // 同时生成增删改查controller
+}
+
+// Result 单个文件的生成结果
+type Result struct {
+	File    string
+	Skipped string // 未写入的原因
+}
+
+type fieldDef struct {
+	Name    string
+	Type    string
+	Tag     string
+	Comment string
+	JSON    string
+}
+
+type modelDef struct {
+	Options
+	Header          string
+	Table           string
+	Title           string
+	Name            string
+	Var             string
+	File            string
+	EmbedModel      bool
+	TableNameMethod bool
+	Fields          []fieldDef
+	Imports         [][]string
+	PK              *fieldDef
+	PKColumn        string
+	ReqFields       []fieldDef // controller请求结构体的字段，不含主键、mysql.Model的列及version
+	ReqImports      []string
+}
+
+// Generate 按表结构生成 model/<表>.gen.go、dao/<表>.gen.go，CRUD时生成controller/<表>.go：
+// .gen.go每次重新生成，不覆盖没有生成标记的文件；controller只在不存在时创建，之后可自由修改
+func Generate(tables []Table, opts Options) ([]Result, error) {
+	if opts.Dir == "" {
+		opts.Dir = "./app"
+	}
+	if opts.Module == "" {
+		opts.Module = "base"
+	}
+	if opts.ModulePath == "" {
+		opts.ModulePath = modulePath()
+	}
+	var results []Result
+	for _, t := range tables {
+		m := buildModel(t, opts)
+		dir := filepath.Join(opts.Dir, opts.Module)
+		files := []output{
+			{filepath.Join(dir, "model", m.File+".gen.go"), modelTpl, false},
+			{filepath.Join(dir, "dao", m.File+".gen.go"), daoTpl, false},
+		}
+		if opts.CRUD {
+			if m.PK == nil {
+				results = append(results, Result{File: t.Name, Skipped: "no primary key, controller not generated"})
+			} else {
+				files = append(files, output{filepath.Join(dir, "controller", m.File+".go"), controllerTpl, true})
+			}
+		}
+		for _, f := range files {
+			result, err := f.write(m)
+			if err != nil {
+				return results, err
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// output 生成的文件，scaffold只在文件不存在时创建
+type output struct {
+	path     string
+	tpl      *template.Template
+	scaffold bool
+}
+
+func (o output) write(m *modelDef) (Result, error) {
+	path := o.path
+	result := Result{File: path}
+	if existing, err := os.ReadFile(path); err == nil {
+		if o.scaffold {
+			result.Skipped = "already exists"
+			return result, nil
+		}
+		if !bytes.HasPrefix(existing, []byte(generatedHeader)) {
+			result.Skipped = "hand-written file, not overwritten"
+			return result, nil
+		}
+	}
+	var buf bytes.Buffer
+	if err := o.tpl.Execute(&buf, m); err != nil {
+		return result, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return result, fmt.Errorf("gen %s: %w\n%s", path, err, buf.String())
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return result, err
+	}
+	return result, os.WriteFile(path, src, 0644)
+}
+
+func buildModel(t Table, opts Options) *modelDef {
+	base := strings.TrimPrefix(t.Name, opts.Prefix)
+	m := &modelDef{Options: opts, Header: generatedHeader, Table: t.Name, Title: oneLine(t.Comment),
+		Name: goName(base), File: strings.ToLower(base)}
+	if m.Title == "" {
+		m.Title = base
+	}
+	m.Var = lowerFirst(m.Name)
+	ns := schema.NamingStrategy{TablePrefix: opts.Prefix, SingularTable: true}
+	m.TableNameMethod = ns.TableName(m.Name) != t.Name
+	m.EmbedModel = embedsModel(t.Columns)
+
+	imports, reqImports := map[string]bool{}, map[string]bool{}
+	if m.EmbedModel {
+		imports[opts.ModulePath+"/pkg/mysql"] = true
+		m.PK = &fieldDef{Name: "ID", Type: "uint64", JSON: "id"}
+		m.PKColumn = "id"
+	}
+	for _, c := range t.Columns {
+		if m.EmbedModel && modelColumns[c.Name] {
+			continue
+		}
+		f := fieldDef{Name: goName(c.Name), JSON: jsonName(c.Name), Comment: oneLine(c.Comment)}
+		var pkg string
+		f.Type, pkg = goType(c)
+		gormTag := "column:" + c.Name
+		if c.IsPrimary() {
+			gormTag += ";primaryKey"
+		}
+		if c.Name == "deleted_at" && f.Type == "*time.Time" {
+			f.Type, pkg, f.JSON = "gorm.DeletedAt", "gorm.io/gorm", "-"
+			gormTag += ";index"
+		}
+		if pkg != "" {
+			imports[pkg] = true
+		}
+		f.Tag = fmt.Sprintf("`gorm:\"%s\" json:\"%s\"`", gormTag, f.JSON)
+		if c.IsPrimary() && m.PK == nil {
+			pk := f
+			m.PK, m.PKColumn = &pk, c.Name
+		}
+		m.Fields = append(m.Fields, f)
+		if !c.IsPrimary() && !modelColumns[c.Name] && c.Name != "version" && f.JSON != "-" {
+			req := f
+			req.Tag = fmt.Sprintf("`json:\"%s\"`", f.JSON)
+			m.ReqFields = append(m.ReqFields, req)
+			if pkg != "" && !reqImports[pkg] {
+				reqImports[pkg] = true
+				m.ReqImports = append(m.ReqImports, pkg)
+			}
+		}
+	}
+	m.Imports = groupImports(imports, opts.ModulePath)
+	return m
+}
+
+// embedsModel 表包含mysql.Model的全部列且类型一致
+func embedsModel(columns []Column) bool {
+	found := 0
+	for _, c := range columns {
+		switch c.Name {
+		case "id":
+			if !c.IsPrimary() || c.DataType != "bigint" || !c.IsUnsigned() {
+				return false
+			}
+		case "created_at", "updated_at":
+			if !isTime(c) || c.IsNullable() {
+				return false
+			}
+		case "deleted_at":
+			if !isTime(c) || !c.IsNullable() {
+				return false
+			}
+		default:
+			continue
+		}
+		found++
+	}
+	return found == len(modelColumns)
+}
+
+func isTime(c Column) bool {
+	return c.DataType == "datetime" || c.DataType == "timestamp"
+}
+
+// goType 列对应的Go类型及需要导入的包，可为NULL的列使用指针
+func goType(c Column) (string, string) {
+	var typ, pkg string
+	unsigned := c.IsUnsigned()
+	integer := func(bits string) string {
+		if unsigned {
+			return "uint" + bits
+		}
+		return "int" + bits
+	}
+	switch c.DataType {
+	case "tinyint":
+		typ = integer("8")
+		if strings.HasPrefix(c.ColumnType, "tinyint(1)") {
+			typ = "bool"
+		}
+	case "smallint", "year":
+		typ = integer("16")
+	case "mediumint", "int", "integer":
+		typ = integer("32")
+	case "bigint":
+		typ = integer("64")
+	case "bit":
+		typ = "uint64"
+	case "float":
+		typ = "float32"
+	case "double", "real":
+		typ = "float64"
+	case "date", "datetime", "timestamp":
+		typ, pkg = "time.Time", "time"
+	case "json":
+		return "json.RawMessage", "encoding/json"
+	case "binary", "varbinary", "blob", "tinyblob", "mediumblob", "longblob":
+		return "[]byte", ""
+	default:
+		// char、varchar、text、enum、set、decimal(保持精度)、time等
+		typ = "string"
+	}
+	if c.IsNullable() {
+		typ = "*" + typ
+	}
+	return typ, pkg
+}
+
+// goName 下划线命名转为Go导出名称，如 user_id => UserID
+func goName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == ' ' }) {
+		part = strings.ToLower(part)
+		if initialisms[part] {
+			b.WriteString(strings.ToUpper(part))
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	s := b.String()
+	if s == "" || unicode.IsDigit(rune(s[0])) {
+		s = "T" + s
+	}
+	return s
+}
+
+// jsonName 小驼峰，与仓库中的json字段风格一致，如 upload_id => uploadId
+func jsonName(column string) string {
+	parts := strings.Split(strings.ToLower(column), "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func lowerFirst(s string) string {
+	for i, r := range s {
+		if !unicode.IsUpper(r) {
+			if i > 1 {
+				// 连续大写的前缀(如 API)只保留最后一个大写字母
+				i--
+			}
+			return strings.ToLower(s[:i]) + s[i:]
+		}
+	}
+	return strings.ToLower(s)
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// groupImports 标准库及本项目一组，第三方一组
+func groupImports(imports map[string]bool, modulePath string) [][]string {
+	var local, external []string
+	for pkg := range imports {
+		first := strings.SplitN(pkg, "/", 2)[0]
+		if strings.Contains(first, ".") && !strings.HasPrefix(pkg, modulePath+"/") {
+			external = append(external, pkg)
+		} else {
+			local = append(local, pkg)
+		}
+	}
+	sort.Strings(local)
+	sort.Strings(external)
+	var groups [][]string
+	for _, g := range [][]string{local, external} {
+		if len(g) > 0 {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// modulePath 当前目录go.mod中的module
+func modulePath() string {
+	f, err := os.Open("go.mod")
+	if err != nil {
+		return "goframe"
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); strings.HasPrefix(line, "module ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "module "))
+		}
+	}
+	return "goframe"
+}
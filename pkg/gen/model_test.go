@@ -0,0 +1,257 @@
+package gen
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"user_id":      "UserID",
+		"name":         "Name",
+		"api_url":      "APIURL",
+		"order-no":     "OrderNo",
+		"UPLOAD_MD5":   "UploadMD5",
+		"2fa_secret":   "T2faSecret",
+		"__":           "T",
+		"created_at":   "CreatedAt",
+		"user_profile": "UserProfile",
+	}
+	for in, want := range tests {
+		if got := goName(in); got != want {
+			t.Errorf("goName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestJSONName(t *testing.T) {
+	tests := map[string]string{
+		"user_id":   "userId",
+		"name":      "name",
+		"UPLOAD_ID": "uploadId",
+		"a__b":      "aB",
+	}
+	for in, want := range tests {
+		if got := jsonName(in); got != want {
+			t.Errorf("jsonName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLowerFirst(t *testing.T) {
+	tests := map[string]string{
+		"User":     "user",
+		"APIToken": "apiToken",
+		"ID":       "id",
+		"UserID":   "userID",
+		"":         "",
+	}
+	for in, want := range tests {
+		if got := lowerFirst(in); got != want {
+			t.Errorf("lowerFirst(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGoType(t *testing.T) {
+	tests := []struct {
+		col     Column
+		typ     string
+		pkgPath string
+	}{
+		{Column{DataType: "tinyint", ColumnType: "tinyint(1)", Nullable: "NO"}, "bool", ""},
+		{Column{DataType: "tinyint", ColumnType: "tinyint(4)", Nullable: "NO"}, "int8", ""},
+		{Column{DataType: "tinyint", ColumnType: "tinyint(3) unsigned", Nullable: "NO"}, "uint8", ""},
+		{Column{DataType: "int", ColumnType: "int(11)", Nullable: "YES"}, "*int32", ""},
+		{Column{DataType: "bigint", ColumnType: "bigint(20) unsigned", Nullable: "NO"}, "uint64", ""},
+		{Column{DataType: "decimal", ColumnType: "decimal(10,2)", Nullable: "NO"}, "string", ""},
+		{Column{DataType: "varchar", ColumnType: "varchar(64)", Nullable: "YES"}, "*string", ""},
+		{Column{DataType: "datetime", ColumnType: "datetime", Nullable: "NO"}, "time.Time", "time"},
+		{Column{DataType: "timestamp", ColumnType: "timestamp", Nullable: "YES"}, "*time.Time", "time"},
+		// json及二进制本身可表示NULL，不使用指针
+		{Column{DataType: "json", ColumnType: "json", Nullable: "YES"}, "json.RawMessage", "encoding/json"},
+		{Column{DataType: "blob", ColumnType: "blob", Nullable: "YES"}, "[]byte", ""},
+	}
+	for _, tt := range tests {
+		typ, pkgPath := goType(tt.col)
+		if typ != tt.typ || pkgPath != tt.pkgPath {
+			t.Errorf("goType(%s nullable=%s) = %s, %q, want %s, %q", tt.col.ColumnType, tt.col.Nullable, typ, pkgPath, tt.typ, tt.pkgPath)
+		}
+	}
+}
+
+// modelColumnsOf mysql.Model的列
+func modelColumnsOf() []Column {
+	return []Column{
+		{Name: "id", DataType: "bigint", ColumnType: "bigint(20) unsigned", Nullable: "NO", Key: "PRI", Extra: "auto_increment"},
+		{Name: "created_at", DataType: "datetime", ColumnType: "datetime(3)", Nullable: "NO"},
+		{Name: "updated_at", DataType: "datetime", ColumnType: "datetime(3)", Nullable: "NO"},
+		{Name: "deleted_at", DataType: "datetime", ColumnType: "datetime(3)", Nullable: "YES"},
+	}
+}
+
+func TestEmbedsModel(t *testing.T) {
+	withColumn := func(name string, fn func(c *Column)) []Column {
+		columns := modelColumnsOf()
+		for i := range columns {
+			if columns[i].Name == name {
+				fn(&columns[i])
+			}
+		}
+		return columns
+	}
+	tests := []struct {
+		name    string
+		columns []Column
+		want    bool
+	}{
+		{"mysql.Model", append(modelColumnsOf(), Column{Name: "name", DataType: "varchar"}), true},
+		{"missing deleted_at", modelColumnsOf()[:3], false},
+		{"signed id", withColumn("id", func(c *Column) { c.ColumnType = "bigint(20)" }), false},
+		{"id not primary", withColumn("id", func(c *Column) { c.Key = "" }), false},
+		{"nullable created_at", withColumn("created_at", func(c *Column) { c.Nullable = "YES" }), false},
+		{"not null deleted_at", withColumn("deleted_at", func(c *Column) { c.Nullable = "NO" }), false},
+		{"date updated_at", withColumn("updated_at", func(c *Column) { c.DataType = "date" }), false},
+	}
+	for _, tt := range tests {
+		if got := embedsModel(tt.columns); got != tt.want {
+			t.Errorf("embedsModel(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func testTables() []Table {
+	return []Table{
+		{
+			Name:    "test_user",
+			Comment: "用户\n信息",
+			Columns: append(modelColumnsOf(),
+				Column{Name: "user_name", DataType: "varchar", ColumnType: "varchar(64)", Nullable: "NO", Comment: "用户名"},
+				Column{Name: "is_admin", DataType: "tinyint", ColumnType: "tinyint(1)", Nullable: "NO"},
+				Column{Name: "birthday", DataType: "date", ColumnType: "date", Nullable: "YES"},
+				Column{Name: "profile", DataType: "json", ColumnType: "json", Nullable: "YES"},
+			),
+		},
+		{
+			Name: "test_user_log",
+			Columns: []Column{
+				{Name: "log_id", DataType: "int", ColumnType: "int(10) unsigned", Nullable: "NO", Key: "PRI"},
+				{Name: "user_id", DataType: "bigint", ColumnType: "bigint(20) unsigned", Nullable: "NO"},
+				{Name: "created_at", DataType: "timestamp", ColumnType: "timestamp", Nullable: "NO"},
+				{Name: "deleted_at", DataType: "datetime", ColumnType: "datetime", Nullable: "YES"},
+			},
+		},
+	}
+}
+
+func TestBuildModel(t *testing.T) {
+	tables := testTables()
+	opts := Options{Prefix: "test_", ModulePath: "goframe"}
+
+	user := buildModel(tables[0], opts)
+	if !user.EmbedModel || user.Name != "User" || user.Var != "user" || user.Title != "用户 信息" || user.TableNameMethod {
+		t.Errorf("buildModel(test_user) = %+v", user)
+	}
+	if len(user.Fields) != 4 || user.PK == nil || user.PK.Name != "ID" {
+		t.Errorf("test_user fields = %+v, pk = %+v, want mysql.Model columns embedded", user.Fields, user.PK)
+	}
+
+	log := buildModel(tables[1], opts)
+	if log.EmbedModel || log.Name != "UserLog" || log.PKColumn != "log_id" || log.PK.Type != "uint32" {
+		t.Errorf("buildModel(test_user_log) = %+v", log)
+	}
+	for _, f := range log.Fields {
+		if f.Name == "DeletedAt" && (f.Type != "gorm.DeletedAt" || f.JSON != "-") {
+			t.Errorf("deleted_at field = %+v, want gorm.DeletedAt", f)
+		}
+	}
+	// 请求结构体不含主键、时间列及软删除列
+	if len(log.ReqFields) != 1 || log.ReqFields[0].Name != "UserID" {
+		t.Errorf("test_user_log request fields = %+v, want [UserID]", log.ReqFields)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{Dir: dir, Prefix: "test_", ModulePath: "goframe", CRUD: true}
+	modelFile := filepath.Join(dir, "base", "model", "user.gen.go")
+	daoFile := filepath.Join(dir, "base", "dao", "user.gen.go")
+	controllerFile := filepath.Join(dir, "base", "controller", "user.go")
+
+	results, err := Generate(testTables()[:1], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Skipped != "" {
+			t.Errorf("first Generate skipped %s: %s", r.File, r.Skipped)
+		}
+	}
+	golden(t, modelFile, "user.model.golden")
+
+	// 手写的dao及已存在的controller不覆盖，带生成标记的model重新生成
+	handWritten := []byte("package dao\n\n// 手写\n")
+	controller := []byte("package controller\n\n// 已修改\n")
+	if err = os.WriteFile(daoFile, handWritten, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(controllerFile, controller, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(modelFile, []byte(generatedHeader+"\n\npackage model\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, err = Generate(testTables()[:1], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := map[string]string{}
+	for _, r := range results {
+		skipped[r.File] = r.Skipped
+	}
+	want := map[string]string{
+		modelFile:      "",
+		daoFile:        "hand-written file, not overwritten",
+		controllerFile: "already exists",
+	}
+	for file, reason := range want {
+		if skipped[file] != reason {
+			t.Errorf("Generate %s skipped = %q, want %q", file, skipped[file], reason)
+		}
+	}
+	if data, _ := os.ReadFile(daoFile); string(data) != string(handWritten) {
+		t.Errorf("hand-written dao overwritten:\n%s", data)
+	}
+	if data, _ := os.ReadFile(controllerFile); string(data) != string(controller) {
+		t.Errorf("existing controller overwritten:\n%s", data)
+	}
+	golden(t, modelFile, "user.model.golden")
+}
+
+// golden 比较生成的文件与testdata中的期望内容，-update时更新期望内容
+func golden(t *testing.T, file string, name string) {
+	t.Helper()
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err = os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ReplaceAll(string(want), "\r\n", "\n") != string(got) {
+		t.Errorf("%s differs from %s:\n%s", file, path, got)
+	}
+}
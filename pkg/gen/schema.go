@@ -0,0 +1,66 @@
+package gen
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Table information_schema中的表结构
+type Table struct {
+	Name    string   `gorm:"column:TABLE_NAME"`
+	Comment string   `gorm:"column:TABLE_COMMENT"`
+	Columns []Column `gorm:"-"`
+}
+
+type Column struct {
+	Name       string `gorm:"column:COLUMN_NAME"`
+	DataType   string `gorm:"column:DATA_TYPE"`
+	ColumnType string `gorm:"column:COLUMN_TYPE"`
+	Nullable   string `gorm:"column:IS_NULLABLE"`
+	Key        string `gorm:"column:COLUMN_KEY"`
+	Extra      string `gorm:"column:EXTRA"`
+	Comment    string `gorm:"column:COLUMN_COMMENT"`
+}
+
+func (c Column) IsNullable() bool {
+	return c.Nullable == "YES"
+}
+
+func (c Column) IsPrimary() bool {
+	return c.Key == "PRI"
+}
+
+func (c Column) IsUnsigned() bool {
+	return strings.Contains(c.ColumnType, "unsigned")
+}
+
+// Inspect 读取当前库的表结构，tables为空时读取prefix开头的全部表
+func Inspect(ctx context.Context, db *gorm.DB, tables []string, prefix string) ([]Table, error) {
+	q := db.WithContext(ctx).Table("information_schema.TABLES").Select("TABLE_NAME, TABLE_COMMENT").
+		Where("TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'")
+	if len(tables) > 0 {
+		q = q.Where("TABLE_NAME IN ?", tables)
+	} else if prefix != "" {
+		q = q.Where("TABLE_NAME LIKE ?", escapeLike(prefix)+"%")
+	}
+	var result []Table
+	if err := q.Order("TABLE_NAME").Scan(&result).Error; err != nil {
+		return nil, err
+	}
+	for i := range result {
+		err := db.WithContext(ctx).Table("information_schema.COLUMNS").
+			Select("COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY, EXTRA, COLUMN_COMMENT").
+			Where("TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", result[i].Name).
+			Order("ORDINAL_POSITION").Scan(&result[i].Columns).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
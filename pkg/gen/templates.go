@@ -0,0 +1,159 @@
+package gen
+
+import "text/template"
+
+var modelTpl = template.Must(template.New("model").Parse(`{{.Header}}
+// 重新生成会覆盖本文件，自定义方法写在同一包的其他文件中
+
+package model
+{{if .Imports}}
+import (
+{{- range $i, $group := .Imports}}{{if $i}}
+{{end}}{{range $group}}
+	"{{.}}"{{end}}{{end}}
+)
+{{end}}
+// {{.Name}} {{.Title}}
+type {{.Name}} struct {
+{{- if .EmbedModel}}
+	mysql.Model
+{{- end}}
+{{- range .Fields}}
+	{{.Name}} {{.Type}} {{.Tag}}{{if .Comment}} // {{.Comment}}{{end}}
+{{- end}}
+}
+{{if .TableNameMethod}}
+func ({{.Name}}) TableName() string {
+	return "{{.Table}}"
+}
+{{end}}`))
+
+var daoTpl = template.Must(template.New("dao").Parse(`{{.Header}}
+// 重新生成会覆盖本文件，自定义查询写在同一包的其他文件中
+
+package dao
+
+import (
+	"{{.ModulePath}}/app/{{.Module}}/model"
+	"{{.ModulePath}}/pkg/mysql"
+)
+
+// {{.Name}}Repository {{.Table}}表的数据访问
+type {{.Name}}Repository struct {
+	*mysql.Repository[model.{{.Name}}]
+}
+
+func New{{.Name}}Repository() *{{.Name}}Repository {
+	return &{{.Name}}Repository{Repository: mysql.NewRepository[model.{{.Name}}]({{if .Datasource}}"{{.Datasource}}"{{end}})}
+}
+`))
+
+var controllerTpl = template.Must(template.New("controller").Parse(`package controller
+
+import (
+	"context"
+	"{{.ModulePath}}/app/{{.Module}}/dao"
+	"{{.ModulePath}}/app/{{.Module}}/model"
+	"{{.ModulePath}}/pkg/handler"
+	"{{.ModulePath}}/pkg/query"
+	"{{.ModulePath}}/pkg/response"
+{{- range .ReqImports}}
+	"{{.}}"
+{{- end}}
+
+	"github.com/gin-gonic/gin"
+)
+
+// {{.Var}}Query 列表接口允许的排序及过滤参数
+var {{.Var}}Query = query.Spec{
+	Sorts:       map[string]string{"{{.PK.JSON}}": "{{.PKColumn}}"},
+	DefaultSort: "-{{.PK.JSON}}",
+	Filters:     map[string]query.Filter{},
+	KeyColumn:   "{{.PKColumn}}",
+}
+
+// {{.Name}}ListReq 分页参数，过滤参数见{{.Var}}Query
+type {{.Name}}ListReq struct {
+	Page   int     ` + "`" + `form:"page"` + "`" + `
+	Size   int     ` + "`" + `form:"size"` + "`" + `
+	Cursor *string ` + "`" + `form:"cursor"` + "`" + `
+	Sort   string  ` + "`" + `form:"sort"` + "`" + `
+}
+
+type {{.Name}}IDReq struct {
+	ID {{.PK.Type}} ` + "`" + `uri:"id" json:"-" binding:"required"` + "`" + `
+}
+
+// {{.Name}}Req 创建、修改时允许客户端写入的字段，不含主键、时间戳及版本号
+type {{.Name}}Req struct {
+{{- range .ReqFields}}
+	{{.Name}} {{.Type}} {{.Tag}}{{if .Comment}} // {{.Comment}}{{end}}
+{{- end}}
+}
+
+type {{.Name}}UpdateReq struct {
+	{{.Name}}IDReq
+	{{.Name}}Req
+}
+
+// apply 将请求中的字段写入m
+func (req {{.Name}}Req) apply(m *model.{{.Name}}) {
+{{- range .ReqFields}}
+	m.{{.Name}} = req.{{.Name}}
+{{- end}}
+}
+
+// {{.Name}}Controller {{.Title}}的增删改查，由gen model创建，之后不会被覆盖
+type {{.Name}}Controller struct {
+	repo *dao.{{.Name}}Repository
+}
+
+// Register{{.Name}}Routes 注册路由，如 controller.Register{{.Name}}Routes(r.Group("/{{.File}}"))
+func Register{{.Name}}Routes(r gin.IRoutes) {
+	ctl := &{{.Name}}Controller{repo: dao.New{{.Name}}Repository()}
+	handler.GET(r, "", ctl.list, handler.Summary("{{.Title}}列表"), handler.Tags("{{.File}}"))
+	handler.GET(r, "/:id", ctl.get, handler.Summary("{{.Title}}详情"), handler.Tags("{{.File}}"))
+	handler.POST(r, "", ctl.create, handler.Summary("创建{{.Title}}"), handler.Tags("{{.File}}"))
+	handler.PUT(r, "/:id", ctl.update, handler.Summary("修改{{.Title}}"), handler.Tags("{{.File}}"))
+	handler.DELETE(r, "/:id", ctl.delete, handler.Summary("删除{{.Title}}"), handler.Tags("{{.File}}"))
+}
+
+// list 分页列表，排序及过滤参数由query.Parse按{{.Var}}Query解析
+func (ctl *{{.Name}}Controller) list(ctx context.Context, _ {{.Name}}ListReq) (*response.Page[model.{{.Name}}], error) {
+	p, err := query.Parse(handler.GinContext(ctx), {{.Var}}Query)
+	if err != nil {
+		return nil, err
+	}
+	return ctl.repo.Paginate(ctx, p)
+}
+
+func (ctl *{{.Name}}Controller) get(ctx context.Context, req {{.Name}}IDReq) (*model.{{.Name}}, error) {
+	return ctl.repo.Get(ctx, req.ID)
+}
+
+func (ctl *{{.Name}}Controller) create(ctx context.Context, req {{.Name}}Req) (*model.{{.Name}}, error) {
+	m := &model.{{.Name}}{}
+	req.apply(m)
+	if err := ctl.repo.Create(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// update 读取记录后用请求中的字段覆盖再保存
+func (ctl *{{.Name}}Controller) update(ctx context.Context, req {{.Name}}UpdateReq) (*model.{{.Name}}, error) {
+	m, err := ctl.repo.Get(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	req.apply(m)
+	if err = ctl.repo.Update(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (ctl *{{.Name}}Controller) delete(ctx context.Context, req {{.Name}}IDReq) (interface{}, error) {
+	return nil, ctl.repo.Delete(ctx, req.ID)
+}
+`))
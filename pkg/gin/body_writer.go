@@ -0,0 +1,74 @@
+package gin
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyWriter 在正常输出的同时记录响应体，用于响应的存储与重放
+type bodyWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func newBodyWriter(w gin.ResponseWriter) *bodyWriter {
+	return &bodyWriter{ResponseWriter: w, body: &bytes.Buffer{}}
+}
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// captureWriter 只记录响应不输出到客户端，由调用方决定最终写出的内容
+type captureWriter struct {
+	gin.ResponseWriter
+	header http.Header
+	status int
+	body   *bytes.Buffer
+}
+
+func newCaptureWriter(w gin.ResponseWriter) *captureWriter {
+	return &captureWriter{ResponseWriter: w, header: http.Header{}, status: http.StatusOK, body: &bytes.Buffer{}}
+}
+
+func (w *captureWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *captureWriter) WriteHeaderNow() {}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *captureWriter) Status() int {
+	return w.status
+}
+
+func (w *captureWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *captureWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+func (w *captureWriter) Flush() {}
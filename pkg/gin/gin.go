@@ -5,16 +5,21 @@ import (
 	"errors"
 	"github.com/HughNian/nmid/pkg/logger"
 	"goframe/pkg/confer"
+	"goframe/pkg/util"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/DeanThompson/ginpprof"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 type OnShutdownF struct {
@@ -48,25 +53,128 @@ func NewGin() *gin.Engine {
 	return r
 }
 
+// newHttpServer 根据http配置创建server，未配置的超时项不做限制
+func newHttpServer(addr string, r http.Handler) *http.Server {
+	conf := confer.GetGlobalConfig().Http
+	return &http.Server{
+		Addr:              addr,
+		Handler:           r,
+		ReadTimeout:       time.Duration(conf.ReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(conf.ReadHeaderTimeout) * time.Second,
+		WriteTimeout:      time.Duration(conf.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(conf.IdleTimeout) * time.Second,
+		MaxHeaderBytes:    conf.MaxHeaderBytes,
+	}
+}
+
+// httpServer 一个http.Server及其全部监听
+type httpServer struct {
+	*http.Server
+	listeners []net.Listener
+}
+
+// newHttpServers 根据配置创建明文及https server
+// 未配置http.listen时监听httpPort；开启tls且未单独配置端口时，这些地址只提供https
+func newHttpServers(httpPort string, r http.Handler) (servers []*httpServer, err error) {
+	conf := confer.GetGlobalConfig().Http
+	addrs := conf.Listen
+	if len(addrs) == 0 {
+		addrs = []string{httpPort}
+	}
+	if !conf.TLS.Enabled || conf.TLS.Port > 0 {
+		handler := r
+		if conf.H2C {
+			handler = h2c.NewHandler(r, &http2.Server{})
+		}
+		srv := &httpServer{Server: newHttpServer(addrs[0], handler)}
+		if err = bindHttpServer(srv, addrs); err != nil {
+			return nil, err
+		}
+		servers = append(servers, srv)
+	}
+	if conf.TLS.Enabled {
+		tlsAddrs := addrs
+		if conf.TLS.Port > 0 {
+			tlsAddrs = []string{":" + strconv.Itoa(conf.TLS.Port)}
+		}
+		srv := &httpServer{Server: newHttpServer(tlsAddrs[0], r)}
+		if srv.TLSConfig, err = newTLSConfig(conf.TLS); err != nil {
+			return nil, err
+		}
+		if err = bindHttpServer(srv, tlsAddrs); err != nil {
+			return nil, err
+		}
+		servers = append(servers, srv)
+	}
+	return
+}
+
+func bindHttpServer(srv *httpServer, addrs []string) error {
+	for _, addr := range addrs {
+		l, err := listen(addr)
+		if err != nil {
+			return err
+		}
+		srv.listeners = append(srv.listeners, l)
+	}
+	return nil
+}
+
+func serveHttp(srv *httpServer, l net.Listener) {
+	var err error
+	if srv.TLSConfig != nil {
+		log.Println("|- https start at:", l.Addr().String())
+		// 证书由TLSConfig.GetCertificate提供
+		err = srv.ServeTLS(l, "", "")
+	} else {
+		log.Println("|- http start at:", l.Addr().String())
+		err = srv.Serve(l)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatalf("listen: %s\n", err)
+	}
+}
+
+// ListenHttp 启动http服务并阻塞至收到退出信号
+// SIGTERM平滑关闭；SIGHUP/SIGUSR2启动新进程接管全部监听，新进程开始服务后平滑关闭当前进程，
+// 新进程启动失败或http.restart-timeout内未就绪时当前进程继续服务
 func ListenHttp(httpPort string, r http.Handler, timeout int, f ...func()) {
-	srv := &http.Server{
-		Addr:    httpPort,
-		Handler: r,
+	servers, err := newHttpServers(httpPort, r)
+	if err != nil {
+		log.Fatalf("listen: %s\n", err)
 	}
+	closeUnusedInheritedListeners()
 	// 监听端口
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+	var listeners []net.Listener
+	for _, srv := range servers {
+		for _, l := range srv.listeners {
+			listeners = append(listeners, l)
+			go serveHttp(srv, l)
 		}
-	}()
+	}
+	// 由平滑重启启动时通知父进程退出
+	notifyReady()
 	// 注册关闭使用函数
 	for _, v := range f {
-		srv.RegisterOnShutdown(v)
+		servers[0].RegisterOnShutdown(v)
 	}
 	// 监听信号
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM)
-	<-quit
+	notifyRestart(quit)
+	for sig := range quit {
+		if !isRestartSignal(sig) {
+			break
+		}
+		if err := restartProcess(listeners, restartTimeout()); err != nil {
+			logger.Errorf("graceful restart error: %s", err.Error())
+			continue
+		}
+		log.Println("Graceful restart, new process ready")
+		// 通知其余主要协程(如nmid worker)随当前进程退出
+		util.GoSecurityOver()
+		break
+	}
 	// 执行on shutdown 函数 - 同步
 	for _, v := range onShutdown {
 		var wg sync.WaitGroup
@@ -87,8 +195,24 @@ func ListenHttp(httpPort string, r http.Handler, timeout int, f ...func()) {
 	log.Println("Shutdown Server ...")
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server Shutdown:", err)
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *httpServer) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Fatal("Server Shutdown:", err)
+			}
+		}(srv)
 	}
+	wg.Wait()
 	log.Println("Server exiting")
 }
+
+// restartTimeout 平滑重启等待新进程就绪的时间，默认60秒
+func restartTimeout() time.Duration {
+	if conf := confer.GetGlobalConfig(); conf != nil && conf.Http.RestartTimeout > 0 {
+		return time.Duration(conf.Http.RestartTimeout) * time.Second
+	}
+	return 60 * time.Second
+}
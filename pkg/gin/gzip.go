@@ -0,0 +1,122 @@
+package gin
+
+import (
+	"compress/gzip"
+	"goframe/pkg/response"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 不压缩的文件类型
+var gzipExcludedExtensions = map[string]bool{".png": true, ".gif": true, ".jpeg": true, ".jpg": true}
+
+// Gzip 压缩响应，压缩规则与gin-contrib/gzip一致，但支持流式输出：
+// Flush时同时刷新压缩缓冲区，首次写出前可通过response.DisableCompression取消压缩(如SSE)。
+func Gzip(level int) gin.HandlerFunc {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		panic(err)
+	}
+	pool := &sync.Pool{New: func() interface{} {
+		gz, _ := gzip.NewWriterLevel(io.Discard, level)
+		return gz
+	}}
+	return func(c *gin.Context) {
+		if !shouldCompress(c.Request) {
+			c.Next()
+			return
+		}
+		w := &gzipWriter{ResponseWriter: c.Writer, pool: pool}
+		c.Writer = w
+		c.Set(response.CompressorKey, w)
+		defer w.close()
+		c.Next()
+	}
+}
+
+func shouldCompress(req *http.Request) bool {
+	if !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") ||
+		strings.Contains(req.Header.Get("Connection"), "Upgrade") ||
+		strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		return false
+	}
+	return !gzipExcludedExtensions[filepath.Ext(req.URL.Path)]
+}
+
+// gzipWriter 在首次写出时才决定是否压缩，无响应体(204/304等)时不输出gzip数据
+type gzipWriter struct {
+	gin.ResponseWriter
+	pool     *sync.Pool
+	gz       *gzip.Writer
+	started  bool
+	disabled bool
+}
+
+// DisableCompression 取消压缩，只在响应写出前生效
+func (w *gzipWriter) DisableCompression() {
+	if !w.started {
+		w.disabled = true
+	}
+}
+
+func (w *gzipWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	if w.disabled {
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Encoding", "gzip")
+	if !strings.Contains(strings.Join(h.Values("Vary"), ","), "Accept-Encoding") {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	h.Del("Content-Length")
+	w.gz = w.pool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+}
+
+func (w *gzipWriter) WriteHeaderNow() {
+	if !w.started && bodyAllowed(w.Status()) {
+		w.start()
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	w.start()
+	if w.gz == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.gz.Write(data)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipWriter) close() {
+	if w.gz == nil {
+		return
+	}
+	_ = w.gz.Close()
+	w.gz.Reset(io.Discard)
+	w.pool.Put(w.gz)
+	w.gz = nil
+}
+
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,117 @@
+package gin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/HughNian/nmid/pkg/logger"
+)
+
+const (
+	// 平滑重启时父进程传递给子进程的监听fd数量
+	envListenFds = "GOFRAME_LISTEN_FDS"
+	// 平滑重启时子进程开始服务后写入的管道fd
+	envReadyFd = "GOFRAME_READY_FD"
+	// systemd socket activation
+	envSystemdListenPid   = "LISTEN_PID"
+	envSystemdListenFds   = "LISTEN_FDS"
+	envSystemdListenNames = "LISTEN_FDNAMES"
+	// 继承的fd从3开始
+	listenFdsStart = 3
+)
+
+var (
+	inheritedOnce      sync.Once
+	inheritedListeners map[string]net.Listener
+)
+
+// parseListenAddr 解析监听地址，支持 ":8080"、"tcp://127.0.0.1:8080"、"unix:///run/app.sock"、"/run/app.sock"
+func parseListenAddr(addr string) (network string, address string) {
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		return "unix", strings.TrimPrefix(addr, "unix://")
+	case strings.HasPrefix(addr, "tcp://"):
+		return "tcp", strings.TrimPrefix(addr, "tcp://")
+	case strings.HasPrefix(addr, "/"), strings.HasPrefix(addr, "@"):
+		return "unix", addr
+	default:
+		return "tcp", addr
+	}
+}
+
+// listenKey 用于匹配配置地址与继承的监听，tcp未指定host时只比较端口
+func listenKey(network string, address string) string {
+	if network != "tcp" {
+		return network + ":" + address
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return network + ":" + address
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = ""
+	}
+	return network + ":" + net.JoinHostPort(host, port)
+}
+
+func listenerKey(l net.Listener) string {
+	addr := l.Addr()
+	return listenKey(addr.Network(), addr.String())
+}
+
+// loadInheritedListeners 读取父进程平滑重启或systemd socket activation传入的监听
+func loadInheritedListeners() map[string]net.Listener {
+	inheritedOnce.Do(func() {
+		inheritedListeners = make(map[string]net.Listener)
+		count, _ := strconv.Atoi(os.Getenv(envListenFds))
+		if count == 0 && os.Getenv(envSystemdListenPid) == strconv.Itoa(os.Getpid()) {
+			count, _ = strconv.Atoi(os.Getenv(envSystemdListenFds))
+		}
+		for _, env := range []string{envListenFds, envSystemdListenPid, envSystemdListenFds, envSystemdListenNames} {
+			_ = os.Unsetenv(env)
+		}
+		for i := 0; i < count; i++ {
+			f := os.NewFile(uintptr(listenFdsStart+i), "listener-"+strconv.Itoa(i))
+			l, err := net.FileListener(f)
+			_ = f.Close()
+			if err != nil {
+				logger.Errorf("inherit listener fd %d error: %s", listenFdsStart+i, err.Error())
+				continue
+			}
+			inheritedListeners[listenerKey(l)] = l
+		}
+	})
+	return inheritedListeners
+}
+
+// listen 优先使用继承的监听，否则新建监听
+func listen(addr string) (net.Listener, error) {
+	network, address := parseListenAddr(addr)
+	key := listenKey(network, address)
+	inherited := loadInheritedListeners()
+	if l, ok := inherited[key]; ok {
+		delete(inherited, key)
+		logger.Infof("use inherited listener: %s", addr)
+		return l, nil
+	}
+	if network == "unix" && !strings.HasPrefix(address, "@") {
+		// 清理上次异常退出残留的socket文件
+		if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove unix socket %s error: %w", address, err)
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// closeUnusedInheritedListeners 关闭与配置不匹配的继承监听
+func closeUnusedInheritedListeners() {
+	for key, l := range loadInheritedListeners() {
+		logger.Errorf("inherited listener %s not configured, closed", key)
+		_ = l.Close()
+		delete(inheritedListeners, key)
+	}
+}
@@ -0,0 +1,62 @@
+package gin
+
+import (
+	"crypto/subtle"
+	"goframe/constv"
+	"goframe/pkg/response"
+	"net"
+	"strings"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminGuard 管理接口的访问控制：配置token时需携带请求头 Authorization: Bearer <token>，
+// 配置allowIPs(ip或cidr)时直连地址需在其中，按RemoteIP判断，不读取X-Forwarded-For。
+// 两者都配置时需同时满足，都为空时不限制，由调用方决定是否注册管理接口。
+func AdminGuard(token string, allowIPs []string) gin.HandlerFunc {
+	nets := make([]*net.IPNet, 0, len(allowIPs))
+	for _, s := range allowIPs {
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			logger.Errorf("admin guard: invalid allow ip %s", s)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return func(c *gin.Context) {
+		if !adminAllowed(c, token, allowIPs, nets) {
+			c.Abort()
+			response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_FORBIDDEN, nil)
+			return
+		}
+		c.Next()
+	}
+}
+
+func adminAllowed(c *gin.Context, token string, allowIPs []string, nets []*net.IPNet) bool {
+	if token != "" {
+		auth := c.GetHeader("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) != 1 {
+			return false
+		}
+	}
+	if len(allowIPs) == 0 {
+		return true
+	}
+	ip := net.ParseIP(c.RemoteIP())
+	for _, ipNet := range nets {
+		if ip != nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminGuard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		token    string
+		allowIPs []string
+		remote   string
+		auth     string
+		want     int
+	}{
+		{"no guard", "", nil, "1.2.3.4:1000", "", http.StatusOK},
+		{"token ok", "secret", nil, "1.2.3.4:1000", "Bearer secret", http.StatusOK},
+		{"token missing", "secret", nil, "1.2.3.4:1000", "", http.StatusForbidden},
+		{"token wrong", "secret", nil, "1.2.3.4:1000", "Bearer secreT", http.StatusForbidden},
+		{"token without bearer", "secret", nil, "1.2.3.4:1000", "secret", http.StatusForbidden},
+		{"ip allowed", "", []string{"10.0.0.0/8"}, "10.1.2.3:1000", "", http.StatusOK},
+		{"single ip allowed", "", []string{"127.0.0.1"}, "127.0.0.1:1000", "", http.StatusOK},
+		{"ip denied", "", []string{"10.0.0.0/8", "127.0.0.1"}, "1.2.3.4:1000", "", http.StatusForbidden},
+		{"token and ip", "secret", []string{"10.0.0.0/8"}, "10.1.2.3:1000", "Bearer secret", http.StatusOK},
+		{"token ok but ip denied", "secret", []string{"10.0.0.0/8"}, "1.2.3.4:1000", "Bearer secret", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/debug", AdminGuard(tt.token, tt.allowIPs), func(c *gin.Context) { c.Status(http.StatusOK) })
+			req := httptest.NewRequest(http.MethodGet, "/debug", nil)
+			req.RemoteAddr = tt.remote
+			// 不信任X-Forwarded-For
+			req.Header.Set("X-Forwarded-For", "10.1.2.3")
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package gin
+
+import (
+	"context"
+	"fmt"
+	"goframe/pkg/confer"
+	"goframe/pkg/httpcache"
+	"goframe/pkg/redis"
+	"goframe/pkg/response"
+	"goframe/pkg/util"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CacheStatusHeader = "X-Cache"
+
+	cacheTagsKey = "goframe_cache_tags"
+)
+
+type cacheOptions struct {
+	ttl   time.Duration
+	stale time.Duration // stale-while-revalidate时长
+	vary  []string      // 参与缓存key计算的请求头
+	tags  []string      // 路由固定的失效标签
+}
+
+type CacheOption func(*cacheOptions)
+
+// WithCacheVary 设置参与缓存key计算的请求头，同时输出到Vary响应头
+func WithCacheVary(headers ...string) CacheOption {
+	return func(o *cacheOptions) { o.vary = append(o.vary, headers...) }
+}
+
+// WithCacheStaleWhileRevalidate 过期后stale时长内先返回旧缓存，再在后台重新生成
+func WithCacheStaleWhileRevalidate(stale time.Duration) CacheOption {
+	return func(o *cacheOptions) { o.stale = stale }
+}
+
+// WithCacheTags 设置路由缓存的失效标签，配合httpcache.InvalidateTags使用
+func WithCacheTags(tags ...string) CacheOption {
+	return func(o *cacheOptions) { o.tags = append(o.tags, tags...) }
+}
+
+// AddCacheTags 在handler中为本次响应追加失效标签，如按记录id打标签
+func AddCacheTags(c *gin.Context, tags ...string) {
+	c.Set(cacheTagsKey, append(c.GetStringSlice(cacheTagsKey), tags...))
+}
+
+// ResponseCache GET接口响应缓存
+// 以请求路径、query参数、响应格式和vary请求头计算key，响应保存在redis中并计算ETag，
+// If-None-Match命中时返回304。请求Cache-Control为no-store时跳过缓存，为no-cache时跳过读取；
+// handler设置Cache-Control为no-store或private，或返回非200时不缓存。
+func ResponseCache(ttl time.Duration, opts ...CacheOption) gin.HandlerFunc {
+	o := &cacheOptions{ttl: ttl}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if !confer.GetGlobalConfig().Redis.Enabled {
+		logger.Error("response cache middleware disabled: redis is not enabled")
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		reqCacheControl := strings.ToLower(c.GetHeader("Cache-Control"))
+		if strings.Contains(reqCacheControl, "no-store") {
+			c.Next()
+			return
+		}
+		key := cacheKey(c, o.vary)
+
+		if !strings.Contains(reqCacheControl, "no-cache") {
+			entry, err := httpcache.Get(key)
+			if err != nil {
+				logger.Errorf("response cache get error: %s", err.Error())
+			}
+			if entry != nil {
+				age := entry.Age()
+				if age < o.ttl {
+					writeCacheEntry(c, o, entry, "HIT")
+					c.Abort()
+					return
+				}
+				if age < o.ttl+o.stale {
+					writeCacheEntry(c, o, entry, "STALE")
+					c.Abort()
+					revalidateCache(c, o, key)
+					return
+				}
+			}
+		}
+
+		entry, ok := renderCacheEntry(c)
+		if ok {
+			storeCacheEntry(c, o, key, entry)
+		}
+		writeCacheEntry(c, o, entry, "MISS")
+	}
+}
+
+// cacheKey 请求方法 + 实际路径 + 排序后的query + 协商的响应格式 + vary请求头，
+// 路径使用URL.Path而不是路由模板，/user/:id的不同id不共用缓存
+func cacheKey(c *gin.Context, vary []string) string {
+	parts := []string{c.Request.Method, c.Request.URL.Path, c.Request.URL.Query().Encode(), response.NegotiatedFormat(c)}
+	for _, h := range vary {
+		parts = append(parts, h+"="+c.GetHeader(h))
+	}
+	return util.MD5(strings.Join(parts, "|"))
+}
+
+// renderCacheEntry 执行后续handler并记录响应，返回的bool表示响应是否可缓存
+func renderCacheEntry(c *gin.Context) (*httpcache.Entry, bool) {
+	origin := c.Writer
+	writer := newCaptureWriter(origin)
+	c.Writer = writer
+	c.Next()
+	c.Writer = origin
+
+	entry, cacheable := newCacheEntry(writer)
+	return entry, cacheable && !c.IsAborted()
+}
+
+// newCacheEntry 由记录的响应生成缓存，handler设置Cache-Control为no-store或private，或返回非200时不可缓存
+func newCacheEntry(writer *captureWriter) (*httpcache.Entry, bool) {
+	header := writer.Header().Clone()
+	header.Del("Content-Length")
+	body := writer.body.Bytes()
+	entry := &httpcache.Entry{
+		Status:   writer.Status(),
+		Header:   header,
+		Body:     body,
+		ETag:     fmt.Sprintf(`"%s"`, util.MD5Bytes(body)),
+		StoredAt: time.Now().Unix(),
+	}
+	respCacheControl := strings.ToLower(header.Get("Cache-Control"))
+	cacheable := entry.Status == http.StatusOK &&
+		!strings.Contains(respCacheControl, "no-store") && !strings.Contains(respCacheControl, "private")
+	return entry, cacheable
+}
+
+func storeCacheEntry(c *gin.Context, o *cacheOptions, key string, entry *httpcache.Entry) {
+	tags := append(append([]string{}, o.tags...), c.GetStringSlice(cacheTagsKey)...)
+	if err := httpcache.Set(key, entry, o.ttl+o.stale, tags...); err != nil {
+		logger.Errorf("response cache set error: %s", err.Error())
+	}
+}
+
+// revalidateCache 旧缓存返回给客户端后在后台重新生成缓存，同一key同时只有一个请求执行。
+// 后台使用c.Copy()及不随请求取消的上下文(保留上下文中的值)，只执行路由的最终handler，
+// 路由上注册在ResponseCache之后的中间件不会执行
+func revalidateCache(c *gin.Context, o *cacheOptions, key string) {
+	handler := c.Handler()
+	cp := c.Copy()
+	ctx, cancel := context.WithTimeout(context.Background(), o.ttl)
+	cp.Request = c.Request.Clone(&valuesContext{Context: ctx, values: c.Request.Context()})
+	go func() {
+		defer cancel()
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Errorf("response cache revalidate panic: %v", err)
+			}
+		}()
+		lock := &redis.DaoRedisEx{KeyName: "httpcache:revalidate", ExpireSecond: int(o.ttl/time.Second) + 1}
+		if reply, err := lock.SetEXNX(key, "1"); err != nil || reply != "OK" {
+			return
+		}
+		defer func() { _ = lock.Del(key) }()
+
+		writer := newCaptureWriter(cp.Writer)
+		cp.Writer = writer
+		handler(cp)
+		if entry, ok := newCacheEntry(writer); ok {
+			storeCacheEntry(cp, o, key, entry)
+		}
+	}()
+}
+
+func writeCacheEntry(c *gin.Context, o *cacheOptions, entry *httpcache.Entry, status string) {
+	header := c.Writer.Header()
+	for k, v := range entry.Header {
+		header[k] = v
+	}
+	header.Set(CacheStatusHeader, status)
+	if entry.Status != http.StatusOK {
+		c.Data(entry.Status, header.Get("Content-Type"), entry.Body)
+		return
+	}
+	header.Set("ETag", entry.ETag)
+	if header.Get("Cache-Control") == "" {
+		cacheControl := fmt.Sprintf("public, max-age=%d", int(o.ttl/time.Second))
+		if o.stale > 0 {
+			cacheControl += fmt.Sprintf(", stale-while-revalidate=%d", int(o.stale/time.Second))
+		}
+		header.Set("Cache-Control", cacheControl)
+	}
+	if status != "MISS" {
+		header.Set("Age", fmt.Sprintf("%d", int(entry.Age()/time.Second)))
+	}
+	response.AddVary(header, o.vary...)
+	if etagMatch(c.GetHeader("If-None-Match"), entry.ETag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Data(entry.Status, header.Get("Content-Type"), entry.Body)
+}
+
+// etagMatch If-None-Match比较，忽略弱校验前缀
+func etagMatch(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, v := range strings.Split(ifNoneMatch, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cacheKeyOf 经过路由/user/:id计算请求的缓存key
+func cacheKeyOf(t *testing.T, method, target string, header map[string]string, vary ...string) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	var key string
+	handler := func(c *gin.Context) { key = cacheKey(c, vary) }
+	r.GET("/user/:id", handler)
+	r.HEAD("/user/:id", handler)
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if key == "" {
+		t.Fatalf("%s %s did not reach the handler", method, target)
+	}
+	return key
+}
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		header map[string]string
+		vary   []string
+		same   bool
+	}{
+		{"another path parameter", http.MethodGet, "/user/2?a=1&b=2", nil, nil, false},
+		{"query order", http.MethodGet, "/user/1?b=2&a=1", nil, nil, true},
+		{"another query", http.MethodGet, "/user/1?a=1&b=3", nil, nil, false},
+		{"head", http.MethodHead, "/user/1?a=1&b=2", nil, nil, false},
+		{"vary header", http.MethodGet, "/user/1?a=1&b=2", map[string]string{"Accept-Language": "en"}, []string{"Accept-Language"}, false},
+		{"header not in vary", http.MethodGet, "/user/1?a=1&b=2", map[string]string{"Accept-Language": "en"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := cacheKeyOf(t, http.MethodGet, "/user/1?a=1&b=2", nil, tt.vary...)
+			key := cacheKeyOf(t, tt.method, tt.target, tt.header, tt.vary...)
+			if (key == base) != tt.same {
+				t.Errorf("cacheKey same as /user/1 = %v, want %v", key == base, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package gin
+
+import (
+	"goframe/pkg/mysql"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadYourWrites 请求内写库后，后续读操作改为读写库
+// dao需使用请求上下文：dao.GetReadOrm().WithContext(c.Request.Context())
+func ReadYourWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(mysql.WithReadYourWrites(c.Request.Context()))
+		c.Next()
+	}
+}
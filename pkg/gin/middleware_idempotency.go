@@ -0,0 +1,259 @@
+package gin
+
+import (
+	"bytes"
+	"encoding/json"
+	"goframe/constv"
+	"goframe/pkg/confer"
+	"goframe/pkg/redis"
+	"goframe/pkg/response"
+	"goframe/pkg/util"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/vmihailenco/msgpack"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	idempotencyProcessing = "processing"
+	idempotencyDone       = "done"
+)
+
+// idempotencyRecord redis中保存的请求状态与最终响应
+type idempotencyRecord struct {
+	State       string              `json:"state"`
+	Fingerprint string              `json:"fingerprint"`
+	Status      int                 `json:"status,omitempty"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+}
+
+type idempotencyOptions struct {
+	ttl      time.Duration // 响应保存时间
+	lockTTL  time.Duration // 处理中锁的过期时间，防止进程异常退出后锁无法释放
+	wait     time.Duration // 并发重复请求等待首个请求完成的时间，0表示直接返回409
+	required bool          // 是否必须携带Idempotency-Key
+}
+
+type IdempotencyOption func(*idempotencyOptions)
+
+// WithIdempotencyLockTTL 设置处理中锁的过期时间
+func WithIdempotencyLockTTL(ttl time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) { o.lockTTL = ttl }
+}
+
+// WithIdempotencyWait 设置并发重复请求的等待时间
+func WithIdempotencyWait(wait time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) { o.wait = wait }
+}
+
+// WithIdempotencyRequired 未携带Idempotency-Key的请求直接返回参数错误
+func WithIdempotencyRequired() IdempotencyOption {
+	return func(o *idempotencyOptions) { o.required = true }
+}
+
+// Idempotency 基于Idempotency-Key请求头的幂等中间件，用于POST等非幂等接口
+// 首个请求通过SET NX获取锁并在完成后保存状态码、响应头和响应体，ttl内的重复请求直接重放；
+// 处理中的重复请求等待完成或返回409；相同key但请求体不同返回422。
+// 5xx、超时及临时性失败的响应不保存，客户端可使用同一key重试。
+func Idempotency(ttl time.Duration, opts ...IdempotencyOption) gin.HandlerFunc {
+	o := &idempotencyOptions{
+		ttl:     ttl,
+		lockTTL: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if !confer.GetGlobalConfig().Redis.Enabled {
+		logger.Error("idempotency middleware disabled: redis is not enabled")
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			if o.required {
+				c.Abort()
+				response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_PARAMS_INCOMPLETE, nil,
+					"missing "+IdempotencyKeyHeader+" header")
+				return
+			}
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Abort()
+			response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_PARAMS_INCOMPLETE, nil)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		dao := &redis.DaoRedisEx{KeyName: "idempotency", ExpireSecond: int(o.lockTTL / time.Second)}
+		redisKey := util.MD5(strings.Join([]string{c.Request.Method, c.FullPath(), key}, " "))
+		fingerprint := util.MD5Bytes(append([]byte(c.Request.URL.RawQuery+" "), body...))
+
+		lock, _ := json.Marshal(idempotencyRecord{State: idempotencyProcessing, Fingerprint: fingerprint})
+		reply, err := dao.SetEXNX(redisKey, string(lock))
+		if err != nil {
+			// redis不可用时放行，避免影响正常业务
+			logger.Errorf("idempotency acquire lock error: %s", err.Error())
+			c.Next()
+			return
+		}
+		if reply != "OK" {
+			handleIdempotencyDuplicate(c, dao, redisKey, fingerprint, o.wait)
+			return
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				_ = dao.Del(redisKey)
+			}
+		}()
+		writer := newBodyWriter(c.Writer)
+		c.Writer = writer
+		c.Next()
+
+		if !idempotencyStorable(c, writer) {
+			return
+		}
+		record := idempotencyRecord{
+			State:       idempotencyDone,
+			Fingerprint: fingerprint,
+			Status:      writer.Status(),
+			Header:      replayHeader(writer.Header()),
+			Body:        writer.body.Bytes(),
+		}
+		if err := dao.SetEx(redisKey, record, int(o.ttl/time.Second)); err != nil {
+			logger.Errorf("idempotency save response error: %s", err.Error())
+			return
+		}
+		completed = true
+	}
+}
+
+// transientCodes 临时性失败的业务code，响应不保存，客户端可使用同一key重试
+var transientCodes = map[int]bool{
+	constv.CODE_COMMON_REQUEST_TIMEOUT:   true,
+	constv.CODE_COMMON_SERVER_OVERLOAD:   true,
+	constv.CODE_COMMON_DB_POOL_EXHAUSTED: true,
+}
+
+// idempotencyStorable 响应是否保存用于重放：5xx、handler未输出、请求上下文已超时或取消、
+// 业务code为临时性失败(超时、过载、数据库繁忙)时不保存
+func idempotencyStorable(c *gin.Context, writer *bodyWriter) bool {
+	if writer.Status() >= http.StatusInternalServerError || !writer.Written() || c.Request.Context().Err() != nil {
+		return false
+	}
+	code, ok := envelopeCode(writer.Header().Get("Content-Type"), writer.body.Bytes())
+	return !ok || !transientCodes[code]
+}
+
+// envelopeCode 读取json或msgpack响应中的code
+func envelopeCode(contentType string, body []byte) (int, bool) {
+	var envelope struct {
+		Code *int `json:"code" msgpack:"code"`
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	var err error
+	switch mediaType {
+	case gin.MIMEJSON:
+		err = json.Unmarshal(body, &envelope)
+	case response.MIMEMsgpack:
+		err = msgpack.Unmarshal(body, &envelope)
+	default:
+		return 0, false
+	}
+	if err != nil || envelope.Code == nil {
+		return 0, false
+	}
+	return *envelope.Code, true
+}
+
+// replayHeader 保存用于重放的响应头。记录的响应体是压缩前的内容，不保存压缩中间件设置的
+// Content-Encoding、Content-Length及Vary: Accept-Encoding，重放时由重放请求的中间件重新压缩
+func replayHeader(h http.Header) map[string][]string {
+	header := make(map[string][]string, len(h))
+	for k, v := range h {
+		switch k {
+		case "Content-Length", "Content-Encoding":
+			continue
+		case "Vary":
+			v = withoutVary(v, "Accept-Encoding")
+			if len(v) == 0 {
+				continue
+			}
+		}
+		header[k] = v
+	}
+	return header
+}
+
+// withoutVary 去掉Vary中的一个请求头
+func withoutVary(values []string, name string) []string {
+	var result []string
+	for _, value := range values {
+		var kept []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" && !strings.EqualFold(v, name) {
+				kept = append(kept, v)
+			}
+		}
+		if len(kept) > 0 {
+			result = append(result, strings.Join(kept, ", "))
+		}
+	}
+	return result
+}
+
+// handleIdempotencyDuplicate 处理重复请求：校验指纹、等待处理中请求、重放已完成的响应
+func handleIdempotencyDuplicate(c *gin.Context, dao *redis.DaoRedisEx, redisKey, fingerprint string, wait time.Duration) {
+	deadline := time.Now().Add(wait)
+	for {
+		var record idempotencyRecord
+		exists, err := dao.GetRaw(redisKey, &record)
+		if err != nil {
+			c.Abort()
+			response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_SERVER_BUSY, nil)
+			return
+		}
+		if exists && record.Fingerprint != fingerprint {
+			c.Abort()
+			response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_IDEMPOTENCY_KEY_REUSED, nil)
+			return
+		}
+		if exists && record.State == idempotencyDone {
+			for k, v := range record.Header {
+				c.Writer.Header()[k] = v
+			}
+			c.Header(IdempotencyReplayedHeader, "true")
+			c.Abort()
+			c.Data(record.Status, c.Writer.Header().Get("Content-Type"), record.Body)
+			return
+		}
+		// 锁已释放(首个请求失败)或等待超时
+		if !exists || !time.Now().Before(deadline) {
+			break
+		}
+		select {
+		case <-c.Request.Context().Done():
+			c.Abort()
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	c.Abort()
+	response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_REQUEST_IN_PROGRESS, nil)
+}
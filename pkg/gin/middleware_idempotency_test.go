@@ -0,0 +1,77 @@
+package gin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vmihailenco/msgpack"
+)
+
+func TestReplayHeader(t *testing.T) {
+	got := replayHeader(http.Header{
+		"Content-Type":     {"application/json; charset=utf-8"},
+		"Content-Length":   {"42"},
+		"Content-Encoding": {"gzip"},
+		"Vary":             {"Origin, Accept-Encoding", "Accept-Encoding"},
+		"X-Request-Id":     {"abc"},
+	})
+	want := map[string][]string{
+		"Content-Type": {"application/json; charset=utf-8"},
+		"Vary":         {"Origin"},
+		"X-Request-Id": {"abc"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replayHeader() = %v, want %v", got, want)
+	}
+}
+
+func TestIdempotencyStorable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	msgpackBody := func(code int) []byte {
+		b, _ := msgpack.Marshal(map[string]interface{}{"code": code, "message": "", "data": nil})
+		return b
+	}
+	tests := []struct {
+		name    string
+		timeout bool
+		handler gin.HandlerFunc
+		want    bool
+	}{
+		{"ok", false, func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"code": 0}) }, true},
+		{"params error", false, func(c *gin.Context) { c.JSON(http.StatusBadRequest, gin.H{"code": 1004}) }, true},
+		{"plain text", false, func(c *gin.Context) { c.String(http.StatusOK, "done") }, true},
+		{"server error", false, func(c *gin.Context) { c.JSON(http.StatusInternalServerError, gin.H{"code": 1003}) }, false},
+		{"not written", false, func(c *gin.Context) {}, false},
+		{"envelope timeout", false, func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"code": 1009}) }, false},
+		{"envelope overload", false, func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"code": 1010}) }, false},
+		{"msgpack db busy", false, func(c *gin.Context) { c.Data(http.StatusOK, "application/msgpack", msgpackBody(1015)) }, false},
+		{"deadline exceeded", true, func(c *gin.Context) {
+			<-c.Request.Context().Done()
+			c.JSON(http.StatusOK, gin.H{"code": 0})
+		}, false},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		var got bool
+		r.POST("/", func(c *gin.Context) {
+			if tt.timeout {
+				ctx, cancel := context.WithTimeout(c.Request.Context(), time.Millisecond)
+				defer cancel()
+				c.Request = c.Request.WithContext(ctx)
+			}
+			writer := newBodyWriter(c.Writer)
+			c.Writer = writer
+			c.Next()
+			got = idempotencyStorable(c, writer)
+		}, tt.handler)
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+		if got != tt.want {
+			t.Errorf("%s: idempotencyStorable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package gin
+
+import (
+	"goframe/constv"
+	"goframe/pkg/response"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 保存请求体限制，路由上的MaxBodySize修改全局设置的限制而不是再包装一层
+const bodyLimitKey = "goframe_body_limit"
+
+// MaxBodySize 限制请求体大小
+// 路由上再次使用MaxBodySize会覆盖全局设置的限制(可调大或调小，0为不限制)，如上传接口，
+// 此时Content-Length超出覆盖后的限制直接返回CODE_COMMON_BODY_TOO_LARGE；
+// 全局的限制在读取请求体时才检查(之后的路由可能调大)，由绑定方法返回错误。
+func MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get(bodyLimitKey)
+		if !ok {
+			if limit > 0 {
+				body := &limitedBody{w: c.Writer, body: c.Request.Body, length: c.Request.ContentLength, limit: limit}
+				c.Request.Body = body
+				c.Set(bodyLimitKey, body)
+			}
+			c.Next()
+			return
+		}
+		v.(*limitedBody).setLimit(limit)
+		if limit > 0 && c.Request.ContentLength > limit {
+			c.Abort()
+			response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_BODY_TOO_LARGE, nil)
+			return
+		}
+		c.Next()
+	}
+}
+
+// limitedBody 首次读取时才按当前的限制包装http.MaxBytesReader，之前路由可以修改限制
+type limitedBody struct {
+	w      http.ResponseWriter
+	body   io.ReadCloser
+	length int64
+	limit  int64
+	reader io.ReadCloser
+}
+
+// setLimit 修改限制，已开始读取请求体时不再生效
+func (b *limitedBody) setLimit(limit int64) {
+	if b.reader == nil {
+		b.limit = limit
+	}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.reader == nil {
+		if b.limit > 0 && b.length > b.limit {
+			// Content-Length已超出，不读取请求体
+			return 0, &http.MaxBytesError{Limit: b.limit}
+		}
+		b.reader = b.body
+		if b.limit > 0 {
+			b.reader = http.MaxBytesReader(b.w, b.body, b.limit)
+		}
+	}
+	return b.reader.Read(p)
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
+
+// MaxInFlight 限制同时处理的请求数，超出时直接返回503卸载流量
+func MaxInFlight(limit int) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	sem := make(chan struct{}, limit)
+	return func(c *gin.Context) {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			c.Next()
+		default:
+			c.Header("Retry-After", "1")
+			c.Abort()
+			response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_SERVER_OVERLOAD, nil)
+		}
+	}
+}
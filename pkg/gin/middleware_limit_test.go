@@ -0,0 +1,120 @@
+package gin
+
+import (
+	"context"
+	"errors"
+	"goframe/pkg/validate"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaxBodySizeRouteOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MaxBodySize(10))
+	read := func(c *gin.Context) {
+		_, err := io.ReadAll(c.Request.Body)
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.Status(http.StatusRequestEntityTooLarge)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+	r.POST("/default", read)
+	r.POST("/raised", MaxBodySize(100), read)
+	r.POST("/lowered", MaxBodySize(5), read)
+	r.POST("/unlimited", MaxBodySize(0), read)
+
+	tests := []struct {
+		path string
+		size int
+		want int
+	}{
+		{"/default", 10, http.StatusOK},
+		{"/default", 50, http.StatusRequestEntityTooLarge},
+		{"/raised", 50, http.StatusOK},
+		{"/raised", 101, http.StatusRequestEntityTooLarge},
+		{"/lowered", 8, http.StatusRequestEntityTooLarge},
+		{"/unlimited", 1000, http.StatusOK},
+	}
+	for _, tt := range tests {
+		for _, chunked := range []bool{false, true} {
+			req := httptest.NewRequest(http.MethodPost, tt.path, io.NopCloser(strings.NewReader(strings.Repeat("a", tt.size))))
+			req.ContentLength = int64(tt.size)
+			if chunked {
+				// 未声明Content-Length，读取时才触发限制
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("POST %s with %d bytes (chunked %v) = %d, want %d", tt.path, tt.size, chunked, w.Code, tt.want)
+			}
+		}
+	}
+}
+
+type testContextKey struct{}
+
+func TestTimeoutRouteOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Timeout(20 * time.Millisecond))
+	r.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), testContextKey{}, "v"))
+	})
+	check := func(want time.Duration) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			ctx := c.Request.Context()
+			if ctx.Value(testContextKey{}) != "v" {
+				t.Errorf("%s: context value lost", c.FullPath())
+			}
+			deadline, ok := ctx.Deadline()
+			switch {
+			case want == 0 && ok:
+				t.Errorf("%s: deadline = %v, want none", c.FullPath(), deadline)
+			case want > 0 && (!ok || time.Until(deadline) > want || time.Until(deadline) < want/2):
+				t.Errorf("%s: deadline in %v, want about %v", c.FullPath(), time.Until(deadline), want)
+			}
+			c.Status(http.StatusOK)
+		}
+	}
+	r.GET("/default", check(20*time.Millisecond))
+	r.GET("/raised", Timeout(time.Minute), check(time.Minute))
+	r.GET("/unlimited", Timeout(0), check(0))
+	for _, path := range []string{"/default", "/raised", "/unlimited"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s = %d", path, w.Code)
+		}
+	}
+}
+
+func TestMaxBodySizeBind(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MaxBodySize(10))
+	r.POST("/bind", func(c *gin.Context) {
+		var req struct {
+			Name string `json:"name"`
+		}
+		if validate.Bind(c, &req) {
+			c.Status(http.StatusOK)
+		}
+	})
+	req := httptest.NewRequest(http.MethodPost, "/bind", strings.NewReader(`{"name":"`+strings.Repeat("a", 50)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), `"code":1011`) {
+		t.Errorf("bind over limit = %d %s, want 413 with code 1011", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,37 @@
+package gin
+
+import (
+	"goframe/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求头中的请求id最大长度，超出或含有非法字符时重新生成
+const maxRequestIDLen = 64
+
+// RequestID 使用请求头X-Request-Id或生成请求id，写入响应头及请求上下文，
+// 下游通过util.RequestID(c.Request.Context())获取，sql日志中带有该id
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(util.HeaderRequestID)
+		if !validRequestID(id) {
+			id = util.NewRequestID()
+		}
+		c.Header(util.HeaderRequestID, id)
+		c.Request = c.Request.WithContext(util.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		if !(ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '-' || ch == '_' || ch == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package gin
+
+import (
+	"context"
+	"errors"
+	"goframe/constv"
+	"goframe/pkg/response"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 保存进入超时中间件前的请求上下文，路由级超时基于它重新计算截止时间
+	baseContextKey = "goframe_base_context"
+	// 最内层超时中间件的上下文，超时后由它返回CODE_COMMON_REQUEST_TIMEOUT
+	timeoutContextKey = "goframe_timeout_context"
+)
+
+// Timeout 请求处理超时
+// 超时后取消请求上下文，gorm(WithContext)与redis(DaoRedisEx.WithContext)调用随之中断，
+// 若handler尚未写出响应则返回CODE_COMMON_REQUEST_TIMEOUT。
+// 路由上再次使用Timeout会覆盖全局设置的超时时间(可延长或缩短，0为不限制)，如上传接口；
+// 只有最内层的超时生效。
+func Timeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var parent context.Context
+		if base, ok := c.Get(baseContextKey); ok {
+			parent = base.(context.Context)
+		} else if timeout <= 0 {
+			c.Next()
+			return
+		} else {
+			parent = c.Request.Context()
+			c.Set(baseContextKey, parent)
+		}
+		if timeout <= 0 {
+			ctx := &valuesContext{Context: parent, values: c.Request.Context()}
+			c.Set(timeoutContextKey, ctx)
+			c.Request = c.Request.WithContext(ctx)
+			c.Next()
+			return
+		}
+		timeoutCtx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+		ctx := context.Context(timeoutCtx)
+		if _, ok := c.Get(timeoutContextKey); ok {
+			ctx = &valuesContext{Context: timeoutCtx, values: c.Request.Context()}
+		}
+		c.Set(timeoutContextKey, ctx)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if active, _ := c.Get(timeoutContextKey); active == ctx && errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.Abort()
+			response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_REQUEST_TIMEOUT, nil)
+		}
+	}
+}
+
+// valuesContext 截止时间及取消来自Context，值来自values，
+// 路由级超时替换截止时间时保留之前的中间件写入请求上下文的值
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c *valuesContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
@@ -0,0 +1,128 @@
+//go:build !windows
+
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/HughNian/nmid/pkg/logger"
+)
+
+// notifyRestart 监听平滑重启信号
+func notifyRestart(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGUSR2)
+}
+
+func isRestartSignal(sig os.Signal) bool {
+	return sig == syscall.SIGHUP || sig == syscall.SIGUSR2
+}
+
+// restartProcess 以相同参数启动新进程并传递全部监听，新进程开始服务后通过管道通知，
+// 新进程退出或timeout内未通知时结束新进程并返回错误，由当前进程继续服务
+func restartProcess(listeners []net.Listener, timeout time.Duration) error {
+	files := make([]*os.File, 0, len(listeners))
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, l := range listeners {
+		fl, ok := l.(interface{ File() (*os.File, error) })
+		if !ok {
+			return errors.New("listener does not support file descriptor: " + l.Addr().String())
+		}
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, v := range os.Environ() {
+		if strings.HasPrefix(v, envListenFds+"=") || strings.HasPrefix(v, envReadyFd+"=") ||
+			strings.HasPrefix(v, envSystemdListenPid+"=") || strings.HasPrefix(v, envSystemdListenFds+"=") ||
+			strings.HasPrefix(v, envSystemdListenNames+"=") {
+			continue
+		}
+		env = append(env, v)
+	}
+	env = append(env, envListenFds+"="+strconv.Itoa(len(files)), envReadyFd+"="+strconv.Itoa(listenFdsStart+len(files)))
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	cmd.ExtraFiles = append(append([]*os.File{}, files...), readyWriter)
+	err = cmd.Start()
+	// 只保留新进程中的写端，新进程退出时读端收到EOF
+	_ = readyWriter.Close()
+	if err != nil {
+		return err
+	}
+	if err = waitReady(ready, timeout); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	// 当前进程关闭时不能删除已交给新进程的socket文件
+	for _, l := range listeners {
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	return nil
+}
+
+// waitReady 等待新进程写入就绪通知
+func waitReady(ready *os.File, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		if _, err := ready.Read(buf); err != nil {
+			result <- fmt.Errorf("new process exited before ready: %w", err)
+			return
+		}
+		result <- nil
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("new process not ready in %s", timeout)
+	}
+}
+
+// notifyReady 平滑重启启动的新进程开始服务后通知父进程，不是由平滑重启启动时不处理
+func notifyReady() {
+	value := os.Getenv(envReadyFd)
+	_ = os.Unsetenv(envReadyFd)
+	fd, err := strconv.Atoi(value)
+	if err != nil || fd < listenFdsStart {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	if _, err = f.Write([]byte{1}); err != nil {
+		logger.Errorf("notify graceful restart ready error: %s", err.Error())
+	}
+	_ = f.Close()
+}
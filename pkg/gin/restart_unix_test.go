@@ -0,0 +1,59 @@
+//go:build !windows
+
+package gin
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWaitReady(t *testing.T) {
+	tests := []struct {
+		name   string
+		child  func(w *os.File)
+		wantOK bool
+	}{
+		{"ready", func(w *os.File) { _, _ = w.Write([]byte{1}); _ = w.Close() }, true},
+		{"exited", func(w *os.File) { _ = w.Close() }, false},
+		{"timeout", func(w *os.File) {}, false},
+	}
+	for _, tt := range tests {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.child(w)
+		err = waitReady(r, 50*time.Millisecond)
+		if (err == nil) != tt.wantOK {
+			t.Errorf("%s: waitReady = %v", tt.name, err)
+		}
+		_ = r.Close()
+		_ = w.Close()
+	}
+}
+
+func TestNotifyReady(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	// notifyReady会关闭该fd，使用复制的fd
+	fd, err := syscall.Dup(int(w.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envReadyFd, strconv.Itoa(fd))
+	notifyReady()
+	if os.Getenv(envReadyFd) != "" {
+		t.Error("ready fd env not cleared")
+	}
+	_ = w.Close()
+	if err = waitReady(r, time.Second); err != nil {
+		t.Errorf("waitReady after notifyReady = %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package gin
+
+import (
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// notifyRestart windows不支持平滑重启
+func notifyRestart(c chan<- os.Signal) {}
+
+func isRestartSignal(sig os.Signal) bool {
+	return false
+}
+
+func restartProcess(listeners []net.Listener, timeout time.Duration) error {
+	return errors.New("graceful restart is not supported on windows")
+}
+
+func notifyReady() {}
@@ -0,0 +1,154 @@
+package gin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"goframe/pkg/confer"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"github.com/fsnotify/fsnotify"
+	"github.com/gin-gonic/gin"
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify-if-given":    tls.VerifyClientCertIfGiven,
+	"require-and-verify": tls.RequireAndVerifyClientCert,
+}
+
+// certReloader 证书文件变化后自动重新加载，新连接使用新证书
+type certReloader struct {
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	sync.RWMutex
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, r.watch()
+}
+
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load tls certificate error: %w", err)
+	}
+	r.Lock()
+	r.cert = &cert
+	r.Unlock()
+	return nil
+}
+
+// watch 监听证书所在目录，兼容k8s secret通过替换软链接更新文件的方式
+func (r *certReloader) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	dirs := map[string]bool{filepath.Dir(r.certFile): true, filepath.Dir(r.keyFile): true}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return err
+		}
+	}
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				if err := r.reload(); err != nil {
+					// 证书与私钥可能未同时更新完成，保留旧证书等待下一次事件
+					logger.Errorf("tls certificate reload error: %s", err.Error())
+					continue
+				}
+				logger.Infof("tls certificate reloaded: %s", r.certFile)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Errorf("tls certificate watcher error: %s", err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
+
+// newTLSConfig 根据配置创建tls.Config，配置了client-ca-file时开启客户端证书校验
+func newTLSConfig(conf confer.HttpTLS) (*tls.Config, error) {
+	if conf.CertFile == "" || conf.KeyFile == "" {
+		return nil, errors.New("tls cert-file and key-file are required")
+	}
+	reloader, err := newCertReloader(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if conf.ClientCAFile != "" {
+		caPem, err := os.ReadFile(conf.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls client ca error: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("no certificate found in %s", conf.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if conf.ClientAuth != "" {
+			authType, ok := clientAuthTypes[conf.ClientAuth]
+			if !ok {
+				return nil, fmt.Errorf("unknown tls client-auth: %s", conf.ClientAuth)
+			}
+			tlsConfig.ClientAuth = authType
+		}
+	}
+	return tlsConfig, nil
+}
+
+// ClientCertificate mTLS下经过client-ca-file校验的客户端证书，明文请求、未提供证书
+// 或证书未经校验(client-auth为request、require)时返回nil
+func ClientCertificate(c *gin.Context) *x509.Certificate {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 || len(c.Request.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return c.Request.TLS.VerifiedChains[0][0]
+}
+
+// ClientIdentity 客户端身份，优先使用URI SAN(如spiffe id)，其次为证书CN，证书未经校验时为空
+func ClientIdentity(c *gin.Context) string {
+	cert := ClientCertificate(c)
+	if cert == nil {
+		return ""
+	}
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	return cert.Subject.CommonName
+}
@@ -0,0 +1,43 @@
+package gin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIdentity(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://cluster.local/ns/default/sa/api")
+	withURI := &x509.Certificate{Subject: pkix.Name{CommonName: "api"}, URIs: []*url.URL{spiffe}}
+	withCN := &x509.Certificate{Subject: pkix.Name{CommonName: "worker"}}
+	tests := []struct {
+		name  string
+		state *tls.ConnectionState
+		want  string
+	}{
+		{"plain", nil, ""},
+		{"no cert", &tls.ConnectionState{}, ""},
+		// client-auth为request、require时证书未经校验，不能作为身份
+		{"unverified", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{withURI}}, ""},
+		{"verified uri", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{withURI},
+			VerifiedChains: [][]*x509.Certificate{{withURI}}}, spiffe.String()},
+		{"verified cn", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{withCN},
+			VerifiedChains: [][]*x509.Certificate{{withCN}}}, "worker"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Request.TLS = tt.state
+		if got := ClientIdentity(c); got != tt.want {
+			t.Errorf("%s: ClientIdentity = %q, want %q", tt.name, got, tt.want)
+		}
+		if cert := ClientCertificate(c); (cert != nil) != (tt.want != "") {
+			t.Errorf("%s: ClientCertificate = %v", tt.name, cert)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"goframe/constv"
+	"goframe/pkg/response"
+	"goframe/pkg/validate"
+	"reflect"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ginContextKey struct{}
+
+// Func 类型化的controller：入参由请求绑定并校验，返回值渲染为code/message/data
+type Func[Req any, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// Wrap 将类型化的controller转换为gin.HandlerFunc
+// 绑定或校验失败返回CODE_COMMON_PARAMS_INCOMPLETE及字段错误；
+// 返回error时按ErrorCode映射为业务code，成功时data为返回值。
+func Wrap[Req any, Resp any](fn Func[Req, Resp]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, target := newRequest[Req]()
+		if err := validate.ShouldBind(c, target); err != nil {
+			if errors.Is(err, validate.ErrBodyTooLarge) {
+				RenderError(c, err)
+				return
+			}
+			response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_PARAMS_INCOMPLETE, validate.ErrorsOf(err))
+			return
+		}
+		ctx := context.WithValue(c.Request.Context(), ginContextKey{}, c)
+		resp, err := fn(ctx, *req)
+		if err != nil {
+			RenderError(c, err)
+			return
+		}
+		response.UtilResponseReturnJsonNoP(c, constv.CODE_SUCCESS_OK, resp)
+	}
+}
+
+// GinContext 在类型化controller中获取gin.Context，用于读取请求头、客户端证书等，
+// 单元测试中直接调用controller时返回nil
+func GinContext(ctx context.Context) *gin.Context {
+	c, _ := ctx.Value(ginContextKey{}).(*gin.Context)
+	return c
+}
+
+// newRequest 创建请求对象，Req为指针类型时同时分配指向的结构体
+func newRequest[Req any]() (*Req, interface{}) {
+	req := new(Req)
+	t := reflect.TypeOf(req).Elem()
+	if t.Kind() == reflect.Ptr {
+		reflect.ValueOf(req).Elem().Set(reflect.New(t.Elem()))
+		return req, *req
+	}
+	return req, req
+}
+
+// ErrorCode 将error映射为业务code及响应信息，未知错误统一为服务繁忙且不向客户端暴露细节
+func ErrorCode(err error) (code int, msg string, data interface{}) {
+	var codeErr *response.CodeError
+	var validateErr validate.Errors
+	switch {
+	case errors.As(err, &codeErr):
+		return codeErr.Code, codeErr.Msg(), codeErr.Data
+	case errors.As(err, &validateErr):
+		return constv.CODE_COMMON_PARAMS_INCOMPLETE, "", validateErr
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return constv.CODE_COMMON_DATA_NOT_EXIST, "", nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return constv.CODE_COMMON_REQUEST_TIMEOUT, "", nil
+	default:
+		return constv.CODE_COMMON_SERVER_BUSY, "", nil
+	}
+}
+
+// RenderError 按ErrorCode输出错误响应，供未使用Wrap的handler复用
+func RenderError(c *gin.Context, err error) {
+	code, msg, data := ErrorCode(err)
+	if code == constv.CODE_COMMON_SERVER_BUSY {
+		logger.Errorf("%s %s handler error: %s", c.Request.Method, c.FullPath(), err.Error())
+	}
+	response.UtilResponseReturnJsonNoP(c, code, data, msg)
+}
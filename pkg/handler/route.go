@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route 类型化路由的描述，供OpenAPI文档生成使用
+type Route struct {
+	Method      string
+	Path        string // gin格式的完整路径，如 /api/users/:id
+	Summary     string
+	Description string
+	Tags        []string
+	Request     reflect.Type
+	Response    reflect.Type
+	middlewares []gin.HandlerFunc
+}
+
+type Option func(*Route)
+
+// Summary 接口摘要
+func Summary(summary string) Option {
+	return func(r *Route) { r.Summary = summary }
+}
+
+// Description 接口详细说明
+func Description(description string) Option {
+	return func(r *Route) { r.Description = description }
+}
+
+// Tags 接口分组
+func Tags(tags ...string) Option {
+	return func(r *Route) { r.Tags = append(r.Tags, tags...) }
+}
+
+// Middleware 仅作用于该路由的中间件，在controller之前执行
+func Middleware(handlers ...gin.HandlerFunc) Option {
+	return func(r *Route) { r.middlewares = append(r.middlewares, handlers...) }
+}
+
+var (
+	routes   []Route
+	routesMu sync.RWMutex
+)
+
+// Handle 注册类型化路由并记录请求、响应类型
+//
+//	handler.GET(r, "/users/:id", userController.Get, handler.Summary("用户详情"))
+func Handle[Req any, Resp any](r gin.IRoutes, method string, relativePath string, fn Func[Req, Resp], opts ...Option) {
+	route := Route{
+		Method:   method,
+		Path:     joinPath(r, relativePath),
+		Request:  reflect.TypeOf((*Req)(nil)).Elem(),
+		Response: reflect.TypeOf((*Resp)(nil)).Elem(),
+	}
+	for _, opt := range opts {
+		opt(&route)
+	}
+	handlers := append(route.middlewares, Wrap(fn))
+	r.Handle(method, relativePath, handlers...)
+
+	routesMu.Lock()
+	routes = append(routes, route)
+	routesMu.Unlock()
+}
+
+func GET[Req any, Resp any](r gin.IRoutes, relativePath string, fn Func[Req, Resp], opts ...Option) {
+	Handle(r, http.MethodGet, relativePath, fn, opts...)
+}
+
+func POST[Req any, Resp any](r gin.IRoutes, relativePath string, fn Func[Req, Resp], opts ...Option) {
+	Handle(r, http.MethodPost, relativePath, fn, opts...)
+}
+
+func PUT[Req any, Resp any](r gin.IRoutes, relativePath string, fn Func[Req, Resp], opts ...Option) {
+	Handle(r, http.MethodPut, relativePath, fn, opts...)
+}
+
+func PATCH[Req any, Resp any](r gin.IRoutes, relativePath string, fn Func[Req, Resp], opts ...Option) {
+	Handle(r, http.MethodPatch, relativePath, fn, opts...)
+}
+
+func DELETE[Req any, Resp any](r gin.IRoutes, relativePath string, fn Func[Req, Resp], opts ...Option) {
+	Handle(r, http.MethodDelete, relativePath, fn, opts...)
+}
+
+// Routes 已注册的类型化路由
+func Routes() []Route {
+	routesMu.RLock()
+	defer routesMu.RUnlock()
+	return append([]Route{}, routes...)
+}
+
+// joinPath 与gin路由组相同的路径拼接规则
+func joinPath(r gin.IRoutes, relativePath string) string {
+	basePath := "/"
+	if g, ok := r.(interface{ BasePath() string }); ok {
+		basePath = g.BasePath()
+	}
+	if relativePath == "" {
+		return basePath
+	}
+	finalPath := path.Join(basePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(finalPath, "/") {
+		return finalPath + "/"
+	}
+	return finalPath
+}
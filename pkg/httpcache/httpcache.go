@@ -0,0 +1,91 @@
+package httpcache
+
+import (
+	"goframe/pkg/redis"
+	"time"
+)
+
+const (
+	keyName    = "httpcache"
+	tagKeyName = "httpcache:tag"
+)
+
+// Entry 缓存的响应
+type Entry struct {
+	Status   int                 `json:"status"`
+	Header   map[string][]string `json:"header"`
+	Body     []byte              `json:"body"`
+	ETag     string              `json:"etag"`
+	StoredAt int64               `json:"storedAt"`
+}
+
+// Age 缓存已存在的时长
+func (e *Entry) Age() time.Duration {
+	return time.Since(time.Unix(e.StoredAt, 0))
+}
+
+func entryDao() *redis.DaoRedisEx {
+	return &redis.DaoRedisEx{KeyName: keyName}
+}
+
+func tagDao() *redis.DaoRedisEx {
+	return &redis.DaoRedisEx{KeyName: tagKeyName}
+}
+
+// Get 读取缓存，不存在时返回nil
+func Get(key string) (*Entry, error) {
+	var entry Entry
+	exists, err := entryDao().GetRaw(key, &entry)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Set 写入缓存并登记到各标签下，expire为redis中的保存时长
+func Set(key string, entry *Entry, expire time.Duration, tags ...string) error {
+	seconds := int(expire / time.Second)
+	if seconds <= 0 {
+		seconds = 1
+	}
+	if err := entryDao().SetEx(key, entry, seconds); err != nil {
+		return err
+	}
+	dao := tagDao()
+	for _, tag := range tags {
+		if err := dao.SAdd(tag, []interface{}{key}); err != nil {
+			return err
+		}
+		// 标签集合的过期时间只延长不缩短，保证其下寿命最长的缓存仍可被失效
+		ttl, err := dao.GetTTL(tag)
+		if err != nil {
+			return err
+		}
+		if ttl < int64(seconds) {
+			if err := dao.Expire(tag, seconds); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// InvalidateTags 删除标签下的全部缓存，service在写操作完成后调用
+func InvalidateTags(tags ...string) error {
+	dao := tagDao()
+	for _, tag := range tags {
+		keys, err := dao.SMembersString(tag)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := entryDao().MDel(keys...); err != nil {
+				return err
+			}
+		}
+		if err := dao.Del(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
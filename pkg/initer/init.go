@@ -4,6 +4,7 @@ import (
 	"goframe/pkg/confer"
 	"goframe/pkg/mysql"
 	"goframe/pkg/redis"
+	"goframe/pkg/storage"
 )
 
 func ConfigAndBase(configURL string) (err error) {
@@ -28,11 +29,21 @@ func OutSideResource() (err error) {
 			return err
 		}
 	}
+	if confer.GetGlobalConfig().Upload.Enabled {
+		err = initUpload()
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// 初始化mysql连接池
+// 初始化mysql连接池，默认数据源及全部命名数据源
 func initMysql() (err error) {
-	err = mysql.InitMysqlPool(confer.GetGlobalConfig().Mysql, false) // 初始化写库，一个
-	return
+	return mysql.Init(confer.GetGlobalConfig().Mysql)
+}
+
+// 初始化上传存储，上传元数据表需先执行 migrate new --template upload 生成迁移文件
+func initUpload() (err error) {
+	return storage.Init(confer.GetGlobalConfig().Upload)
 }
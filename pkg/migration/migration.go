@@ -0,0 +1,251 @@
+package migration
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"errors"
+	"fmt"
+	"goframe/pkg/confer"
+	"goframe/pkg/mysql"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+const (
+	dialect            = "mysql"
+	defaultDir         = "./db"
+	defaultTable       = "gorp_migrations"
+	defaultLockTimeout = 60
+)
+
+var embedFS fs.FS
+
+// SetEmbedFS 使用go:embed打包的迁移文件，代替migrate.dir，需在InitService之前调用，
+// 命名数据源的迁移文件在以数据源名称命名的子目录
+//
+//	//go:embed db
+//	var migrations embed.FS
+//	migration.SetEmbedFS(migrations, "db")
+func SetEmbedFS(fsys fs.FS, sub string) error {
+	if sub != "" && sub != "." {
+		var err error
+		if fsys, err = fs.Sub(fsys, sub); err != nil {
+			return err
+		}
+	}
+	embedFS = fsys
+	return nil
+}
+
+// Record 迁移文件及执行时间，AppliedAt为空表示未执行，Missing表示已执行但文件已不存在
+type Record struct {
+	ID        string
+	AppliedAt *time.Time
+	Missing   bool
+}
+
+// Migrator 一个数据源的迁移
+type Migrator struct {
+	datasource  string
+	db          *sql.DB
+	set         migrate.MigrationSet
+	fsys        fs.FS
+	source      migrate.MigrationSource
+	lockName    string
+	lockTimeout int
+}
+
+// Dir 数据源的迁移文件目录
+func Dir(datasource string) string {
+	dir := confer.GetGlobalConfig().Mysql.Migrate.Dir
+	if dir == "" {
+		dir = defaultDir
+	}
+	if datasource != "" && datasource != mysql.DefaultDatasource {
+		dir = filepath.Join(dir, datasource)
+	}
+	return dir
+}
+
+// New 数据源的迁移，datasource为空时使用默认数据源
+func New(datasource string) (*Migrator, error) {
+	if datasource == "" {
+		datasource = mysql.DefaultDatasource
+	}
+	conf := confer.GetGlobalConfig().Mysql
+	if !conf.Enabled {
+		return nil, errors.New("migrate: mysql is not enabled")
+	}
+	dbName := conf.DBName
+	if datasource != mysql.DefaultDatasource {
+		dbName = conf.Datasources[datasource].DBName
+	}
+	orm := mysql.NewDaoMysql(datasource).GetWriteOrm()
+	if orm.DB == nil {
+		return nil, fmt.Errorf("migrate: mysql datasource %s is not initialized", datasource)
+	}
+	db, err := orm.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Migrator{datasource: datasource, db: db, lockTimeout: conf.Migrate.LockTimeout}
+	m.set.TableName = conf.Migrate.Table
+	if m.set.TableName == "" {
+		m.set.TableName = defaultTable
+	}
+	if m.lockTimeout <= 0 {
+		m.lockTimeout = defaultLockTimeout
+	}
+	// GET_LOCK在整个mysql实例内有效，名称最长64个字符
+	m.lockName = fmt.Sprintf("goframe_migrate_%x", md5.Sum([]byte(dbName+"."+m.set.TableName)))
+
+	if embedFS != nil {
+		m.fsys = embedFS
+		if datasource != mysql.DefaultDatasource {
+			if m.fsys, err = fs.Sub(embedFS, datasource); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		m.fsys = os.DirFS(Dir(datasource))
+	}
+	m.source = migrate.HttpFileSystemMigrationSource{FileSystem: http.FS(m.fsys)}
+	return m, nil
+}
+
+// HasDir 迁移目录是否存在，命名数据源可以没有迁移目录
+func (m *Migrator) HasDir() bool {
+	_, err := fs.Stat(m.fsys, ".")
+	return err == nil
+}
+
+// Status 全部迁移文件及已执行的记录
+func (m *Migrator) Status() ([]Record, error) {
+	migrations, err := m.source.FindMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.set.GetMigrationRecords(m.db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[string]time.Time, len(applied))
+	for _, r := range applied {
+		appliedAt[r.Id] = r.AppliedAt
+	}
+	records := make([]Record, 0, len(migrations))
+	for _, migration := range migrations {
+		record := Record{ID: migration.Id}
+		if t, ok := appliedAt[migration.Id]; ok {
+			record.AppliedAt = &t
+			delete(appliedAt, migration.Id)
+		}
+		records = append(records, record)
+	}
+	for _, r := range applied {
+		if _, ok := appliedAt[r.Id]; ok {
+			t := r.AppliedAt
+			records = append(records, Record{ID: r.Id, AppliedAt: &t, Missing: true})
+		}
+	}
+	return records, nil
+}
+
+// Up 执行未执行的迁移，max为0时全部执行，返回执行的数量
+func (m *Migrator) Up(ctx context.Context, max int) (int, error) {
+	return m.withLock(ctx, func() (int, error) {
+		return m.set.ExecMax(m.db, dialect, m.source, migrate.Up, max)
+	})
+}
+
+// Down 回滚最近执行的max个迁移
+func (m *Migrator) Down(ctx context.Context, max int) (int, error) {
+	return m.withLock(ctx, func() (int, error) {
+		return m.set.ExecMax(m.db, dialect, m.source, migrate.Down, max)
+	})
+}
+
+// Redo 回滚并重新执行最近的一个迁移
+func (m *Migrator) Redo(ctx context.Context) (int, error) {
+	return m.withLock(ctx, func() (int, error) {
+		n, err := m.set.ExecMax(m.db, dialect, m.source, migrate.Down, 1)
+		if err != nil || n == 0 {
+			return n, err
+		}
+		return m.set.ExecMax(m.db, dialect, m.source, migrate.Up, 1)
+	})
+}
+
+// withLock 使用mysql的GET_LOCK，多个实例同时迁移时依次执行，
+// 后执行的实例获得锁时迁移已完成，不会重复执行
+func (m *Migrator) withLock(ctx context.Context, fn func() (int, error)) (int, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	var locked sql.NullInt64
+	if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", m.lockName, m.lockTimeout).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return 0, fmt.Errorf("migrate: wait for lock of datasource %s timeout", m.datasource)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", m.lockName); err != nil {
+			logger.Errorf("migrate: release lock of datasource %s error: %v", m.datasource, err)
+		}
+	}()
+	return fn()
+}
+
+var migrationName = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// Create 在数据源的迁移目录创建迁移文件，文件名以时间为前缀保证执行顺序
+func Create(datasource, name string) (string, error) {
+	return CreateFrom(datasource, name, "-- +migrate Up\n\n\n-- +migrate Down\n\n")
+}
+
+// CreateFrom 同Create，文件内容为content，用于复制框架提供的迁移模板(如上传元数据表)
+func CreateFrom(datasource, name, content string) (string, error) {
+	if !migrationName.MatchString(name) {
+		return "", fmt.Errorf("migrate: invalid migration name %q, use letters, digits and _", name)
+	}
+	dir := Dir(datasource)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, fmt.Sprintf("%s_%s.sql", time.Now().Format("20060102150405"), name))
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// AutoUp 启动时对全部数据源执行up，没有迁移目录的数据源跳过
+func AutoUp(ctx context.Context) error {
+	for _, name := range mysql.Datasources() {
+		m, err := New(name)
+		if err != nil {
+			return err
+		}
+		if !m.HasDir() {
+			continue
+		}
+		n, err := m.Up(ctx, 0)
+		if err != nil {
+			return fmt.Errorf("migrate datasource %s: %w", name, err)
+		}
+		logger.Infof("migrate datasource %s: %d migrations applied", name, n)
+	}
+	return nil
+}
@@ -5,23 +5,31 @@ import (
 	"fmt"
 	"github.com/HughNian/nmid/pkg/logger"
 	"goframe/pkg/confer"
-	"log"
-	"math/rand"
-	"os"
+	"sort"
+	"sync"
 	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	glogger "gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 )
 
+// DefaultDatasource 默认数据源的名称
+const DefaultDatasource = "default"
+
 type DaoMysql struct {
 	TableName string
+	// 数据源名称，为空时使用默认数据源
+	Datasource string
 }
 
-func NewDaoMysql() *DaoMysql {
-	return &DaoMysql{}
+// NewDaoMysql 不传名称时使用默认数据源，NewDaoMysql("billing")使用mysql.datasources中的数据源
+func NewDaoMysql(datasource ...string) *DaoMysql {
+	dao := &DaoMysql{}
+	if len(datasource) > 0 {
+		dao.Datasource = datasource[0]
+	}
+	return dao
 }
 
 type MysqlConnection struct {
@@ -39,94 +47,149 @@ func (p MysqlConnection) Put() {
 	//db database sql inner put
 }
 
+// datasource 一个数据源的写库及读库，未配置读库时read为空
+type datasource struct {
+	name     string
+	write    MysqlConnection
+	read     MysqlConnection
+	replicas *replicaSet
+}
+
 var (
-	mysqlReadPool  MysqlConnection
-	mysqlWritePool MysqlConnection
+	datasourcesMu sync.RWMutex
+	datasources   = map[string]*datasource{}
 )
 
+// Init 初始化默认数据源、mysql.datasources中的全部数据源及分片规则
+func Init(conf confer.Mysql) (err error) {
+	if err = InitDatasource(DefaultDatasource, conf.MysqlSource); err != nil {
+		return
+	}
+	for name, source := range conf.Datasources {
+		if err = InitDatasource(name, source); err != nil {
+			return
+		}
+	}
+	return InitSharding(conf.Sharding)
+}
+
+// InitMysqlPool 初始化默认数据源的连接池，isRead为true时连接全部读库，需在写库之后初始化
 func InitMysqlPool(conf confer.Mysql, isRead bool) (err error) {
-	if isRead {
-		mysqlReadPool.DB, err = initDb(conf, isRead)
-		mysqlReadPool.IsRead = isRead
-	} else {
-		mysqlWritePool.DB, err = initDb(conf, isRead)
-		mysqlWritePool.IsRead = isRead
+	if !isRead {
+		return initWriter(DefaultDatasource, conf.MysqlSource)
 	}
-	if err != nil {
+	ds := lookup(DefaultDatasource)
+	if ds == nil {
+		return errors.New("initMysqlPool isread:true ,error: write db must be initialized before read replicas")
+	}
+	if err = initReplicas(ds, conf.MysqlSource); err != nil {
 		err = errors.New(fmt.Sprintf("initMysqlPool isread:%v ,error: %v", isRead, err))
+	}
+	return
+}
+
+// InitDatasource 初始化一个数据源的写库及读库
+func InitDatasource(name string, conf confer.MysqlSource) (err error) {
+	if err = initWriter(name, conf); err != nil {
 		return
 	}
-	if isRead {
-		sqlDB, err := mysqlReadPool.DB.DB()
-		if err != nil {
-			err = errors.New(fmt.Sprintf("initMysqlPool isread:%v ,error: %v", isRead, err))
-			return err
-		}
-		sqlDB.SetMaxIdleConns(conf.Pool.PoolMinCap)                       // 空闲链接
-		sqlDB.SetMaxOpenConns(conf.Pool.PoolMaxCap)                       // 最大链接
-		sqlDB.SetConnMaxLifetime(conf.Pool.PoolIdleTimeout * time.Second) // 最大空闲时间
-	} else {
-		sqlDB, err := mysqlWritePool.DB.DB()
-		if err != nil {
-			err = errors.New(fmt.Sprintf("initMysqlPool isread:%v ,error: %v", isRead, err))
-			return err
-		}
-		sqlDB.SetMaxIdleConns(conf.Pool.PoolMinCap)                       // 空闲链接
-		sqlDB.SetMaxOpenConns(conf.Pool.PoolMaxCap)                       // 最大链接
-		sqlDB.SetConnMaxLifetime(conf.Pool.PoolIdleTimeout * time.Second) // 最大空闲时间
+	if err = initReplicas(lookup(name), conf); err != nil {
+		err = errors.New(fmt.Sprintf("init mysql datasource %s replicas error: %v", name, err))
 	}
 	return
 }
 
-func initDb(conf confer.Mysql, isRead bool) (resultDb *gorm.DB, err error) {
-	var dbConfig confer.DBBase
-	if isRead && len(conf.Reads) > 0 {
-		rand.Seed(time.Now().UnixNano())
-		dbConfig = conf.Reads[rand.Intn(len(conf.Reads)-1)]
-	} else {
-		dbConfig = conf.Write
-	}
-	// 判断配置可用性
-	if dbConfig.Host == "" || dbConfig.DBName == "" {
-		err = errors.New("dbConfig is null")
+func initWriter(name string, conf confer.MysqlSource) (err error) {
+	ds := &datasource{name: name}
+	ds.write.DB, err = initDb(name, conf, conf.Write)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("init mysql datasource %s write error: %v", name, err))
 		return
 	}
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4,utf8&parseTime=True&loc=Local", dbConfig.User,
+	sqlDB, err := ds.write.DB.DB()
+	if err != nil {
+		err = errors.New(fmt.Sprintf("init mysql datasource %s write error: %v", name, err))
+		return err
+	}
+	setPool(sqlDB, conf.Pool)
+	registerWriteMarker(ds.write.DB, name)
+	if err = registerPoolLimit(ds.write.DB); err != nil {
+		return err
+	}
+	if err = registerExplain(ds.write.DB, name); err != nil {
+		return err
+	}
+	keepAlive(name, sqlDB, conf.Pool)
+
+	datasourcesMu.Lock()
+	datasources[name] = ds
+	datasourcesMu.Unlock()
+	return
+}
+
+func lookup(name string) *datasource {
+	if name == "" {
+		name = DefaultDatasource
+	}
+	datasourcesMu.RLock()
+	defer datasourcesMu.RUnlock()
+	return datasources[name]
+}
+
+// Datasources 已初始化的数据源名称
+func Datasources() []string {
+	datasourcesMu.RLock()
+	defer datasourcesMu.RUnlock()
+	names := make([]string, 0, len(datasources))
+	for name := range datasources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func dsn(dbConfig confer.DBBase) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4,utf8&parseTime=True&loc=Local", dbConfig.User,
 		dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.DBName)
-	config := &gorm.Config{
+}
+
+func gormConfig(name string, conf confer.MysqlSource) *gorm.Config {
+	return &gorm.Config{
 		SkipDefaultTransaction: true,
 		NamingStrategy: schema.NamingStrategy{
 			TablePrefix:   conf.Prefix, // 表名前缀，`User`表为`t_users`
 			SingularTable: true,        // 使用单数表名，启用该选项后，`User` 表将是`user`
 		},
+		Logger: newQueryLogger(name),
 	}
-	if confer.ConfigEnvIsDev() {
-		newLogger := glogger.New(
-			log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
-			glogger.Config{
-				SlowThreshold: time.Second,  // 慢 SQL 阈值
-				LogLevel:      glogger.Info, // Log level
-				Colorful:      true,         // 禁用彩色打印
-			},
-		)
-		config.Logger = newLogger
-	}
-	resultDb, err = gorm.Open(mysql.Open(dsn), config)
-	if err != nil {
-		logger.Errorf("connect mysql error", err)
-		return resultDb, err
-	}
-
-	return resultDb, err
 }
 
-func initMysqlPoolConnection(isRead bool) (conn MysqlConnection) {
-	if isRead {
-		conn = mysqlReadPool
-	} else {
-		conn = mysqlWritePool
+func initDb(name string, conf confer.MysqlSource, dbConfig confer.DBBase) (resultDb *gorm.DB, err error) {
+	// 判断配置可用性
+	if dbConfig.Host == "" || dbConfig.DBName == "" {
+		err = errors.New("dbConfig is null")
+		return
+	}
+	// 启动时数据库可能尚未就绪，按pool-connect-retries重试
+	retries := conf.Pool.PoolConnectRetries
+	for attempt := 0; ; attempt++ {
+		resultDb, err = gorm.Open(mysql.Open(dsn(dbConfig)), gormConfig(name, conf))
+		if err == nil {
+			return resultDb, nil
+		}
+		if resultDb != nil {
+			if sqlDB, e := resultDb.DB(); e == nil {
+				_ = sqlDB.Close()
+			}
+		}
+		if attempt >= retries {
+			logger.Errorf("connect mysql %s error: %v", name, err)
+			return nil, err
+		}
+		backoff := connectBackoff(attempt)
+		logger.Warnf("connect mysql %s error, retry %d/%d in %s: %v", name, attempt+1, retries, backoff, err)
+		time.Sleep(backoff)
 	}
-	return
 }
 
 func (p *DaoMysql) GetReadOrm() MysqlConnection {
@@ -141,6 +204,15 @@ func (p *DaoMysql) GetOrm() MysqlConnection {
 	return p.getOrm(false)
 }
 
+// getOrm 未配置读库时读操作使用写库，数据源未初始化时返回空连接
 func (p *DaoMysql) getOrm(isRead bool) MysqlConnection {
-	return initMysqlPoolConnection(isRead)
+	ds := lookup(p.Datasource)
+	if ds == nil {
+		logger.Errorf("mysql datasource %s is not initialized", p.datasourceName())
+		return MysqlConnection{IsRead: isRead}
+	}
+	if isRead && ds.read.DB != nil {
+		return ds.read
+	}
+	return ds.write
 }
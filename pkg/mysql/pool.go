@@ -0,0 +1,208 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"goframe/constv"
+	"goframe/pkg/confer"
+	"goframe/pkg/response"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	connectRetryBackoff    = time.Second
+	maxConnectRetryBackoff = 30 * time.Second
+	warmTimeout            = 5 * time.Second
+)
+
+// ErrPoolExhausted 连接全部被占用，等待连接的请求数超过pool-wait-count或等待超过pool-wait-timeout
+var ErrPoolExhausted = response.NewCodeError(constv.CODE_COMMON_DB_POOL_EXHAUSTED)
+
+// poolLimit database/sql对等待连接没有限制，由此限制等待的请求数及时间：
+// 语句执行期间及事务期间各占用一个名额，名额数为pool-max-cap，没有空闲名额时等待
+type poolLimit struct {
+	slots       chan struct{}
+	waitCount   int64
+	waitTimeout time.Duration
+	waiting     atomic.Int64
+	exhausted   atomic.Int64
+}
+
+// poolLimits *sql.DB => *poolLimit
+var poolLimits sync.Map
+
+func setPool(sqlDB *sql.DB, pool confer.DBPool) {
+	sqlDB.SetMaxIdleConns(pool.PoolMinCap + pool.PoolExCap)      // 空闲链接
+	sqlDB.SetMaxOpenConns(pool.PoolMaxCap)                       // 最大链接
+	sqlDB.SetConnMaxIdleTime(pool.PoolIdleTimeout * time.Second) // 最大空闲时间
+	sqlDB.SetConnMaxLifetime(pool.PoolMaxLifetime * time.Second) // 最长使用时间
+	limit := &poolLimit{waitCount: pool.PoolWaitCount, waitTimeout: pool.PoolWaitTimeout * time.Second}
+	if pool.PoolMaxCap > 0 {
+		limit.slots = make(chan struct{}, pool.PoolMaxCap)
+	}
+	poolLimits.Store(sqlDB, limit)
+}
+
+func limitOf(sqlDB *sql.DB) *poolLimit {
+	if v, ok := poolLimits.Load(sqlDB); ok {
+		return v.(*poolLimit)
+	}
+	return nil
+}
+
+// acquire 占用一个名额，没有空闲名额时按pool-wait-count及pool-wait-timeout等待，
+// 成功时返回的release需在语句或事务结束时调用
+func (l *poolLimit) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil || l.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+	n := l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+	if l.waitCount > 0 && n > l.waitCount {
+		l.exhausted.Add(1)
+		return nil, ErrPoolExhausted
+	}
+	var timeout <-chan time.Time
+	if l.waitTimeout > 0 {
+		timer := time.NewTimer(l.waitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	case <-timeout:
+		l.exhausted.Add(1)
+		return nil, ErrPoolExhausted
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *poolLimit) release() {
+	<-l.slots
+}
+
+// callbackProcessor gorm各类语句的callback处理器
+type callbackProcessor interface {
+	Get(name string) func(*gorm.DB)
+	Replace(name string, fn func(*gorm.DB)) error
+}
+
+// registerPoolLimit 包装gorm执行语句的callback，执行期间占用连接池名额，
+// 名额在同一函数内释放，callback panic时也会归还；
+// Row/Rows返回的结果由调用方读取，读取期间不占用名额
+func registerPoolLimit(db *gorm.DB) error {
+	cb := db.Callback()
+	processors := []struct {
+		p    callbackProcessor
+		name string
+	}{
+		{cb.Query(), "gorm:query"},
+		{cb.Create(), "gorm:create"},
+		{cb.Update(), "gorm:update"},
+		{cb.Delete(), "gorm:delete"},
+		{cb.Raw(), "gorm:raw"},
+		{cb.Row(), "gorm:row"},
+	}
+	for _, c := range processors {
+		fn := c.p.Get(c.name)
+		if fn == nil {
+			return fmt.Errorf("mysql: callback %s not found", c.name)
+		}
+		if err := c.p.Replace(c.name, limitStatement(fn)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// limitStatement 语句在连接池上执行时先获取名额，事务中的语句使用事务的名额
+func limitStatement(fn func(*gorm.DB)) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		sqlDB, ok := db.Statement.ConnPool.(*sql.DB)
+		if !ok || db.Error != nil || db.DryRun {
+			fn(db)
+			return
+		}
+		release, err := limitOf(sqlDB).acquire(db.Statement.Context)
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		defer release()
+		fn(db)
+	}
+}
+
+// connectBackoff 第attempt次重试前的等待时间，从1秒开始翻倍
+func connectBackoff(attempt int) time.Duration {
+	backoff := connectRetryBackoff << uint(attempt)
+	if backoff <= 0 || backoff > maxConnectRetryBackoff {
+		backoff = maxConnectRetryBackoff
+	}
+	return backoff
+}
+
+// keepAlive 定期ping写库，并补足pool-min-cap个连接
+func keepAlive(name string, sqlDB *sql.DB, pool confer.DBPool) {
+	warm(sqlDB, pool.PoolMinCap)
+	if pool.PoolPingInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(pool.PoolPingInterval * time.Second)
+		defer ticker.Stop()
+		failed := false
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+			err := sqlDB.PingContext(ctx)
+			cancel()
+			switch {
+			case err != nil:
+				logger.Errorf("mysql %s ping error: %v", name, err)
+			case failed:
+				logger.Infof("mysql %s ping recovered", name)
+			}
+			failed = err != nil
+			if !failed {
+				warm(sqlDB, pool.PoolMinCap)
+			}
+		}
+	}()
+}
+
+// warm 连接数不足n时建立新连接，同时占用n个连接后全部归还，使其成为空闲连接
+func warm(sqlDB *sql.DB, n int) {
+	stats := sqlDB.Stats()
+	if n <= 0 || stats.OpenConnections >= n {
+		return
+	}
+	if stats.MaxOpenConnections > 0 && n > stats.MaxOpenConnections-stats.InUse {
+		n = stats.MaxOpenConnections - stats.InUse
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), warmTimeout)
+	defer cancel()
+	conns := make([]*sql.Conn, 0, n)
+	for i := 0; i < n; i++ {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			break
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+}
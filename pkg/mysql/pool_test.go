@@ -0,0 +1,63 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolLimitAcquire(t *testing.T) {
+	l := &poolLimit{slots: make(chan struct{}, 1), waitCount: 1, waitTimeout: 20 * time.Millisecond}
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有空闲名额时等待超时
+	if _, err = l.acquire(context.Background()); err != ErrPoolExhausted {
+		t.Fatalf("acquire while full = %v, want ErrPoolExhausted", err)
+	}
+
+	// 等待中的请求数超过wait-count
+	waiting := make(chan error, 1)
+	go func() {
+		r, err := l.acquire(context.Background())
+		if err == nil {
+			r()
+		}
+		waiting <- err
+	}()
+	for l.waiting.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err = l.acquire(context.Background()); err != ErrPoolExhausted {
+		t.Fatalf("acquire over wait-count = %v, want ErrPoolExhausted", err)
+	}
+	release()
+	if err = <-waiting; err != nil {
+		t.Fatalf("waiting acquire = %v, want nil after release", err)
+	}
+
+	// 请求上下文取消
+	release, _ = l.acquire(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = l.acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("acquire with canceled context = %v, want context.Canceled", err)
+	}
+	release()
+	if got := l.exhausted.Load(); got != 2 {
+		t.Errorf("exhausted = %d, want 2", got)
+	}
+	if len(l.slots) != 0 {
+		t.Errorf("slots in use = %d, want 0", len(l.slots))
+	}
+
+	// 未限制最大连接数
+	var unlimited *poolLimit
+	if release, err = unlimited.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
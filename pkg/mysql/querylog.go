@@ -0,0 +1,414 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"goframe/pkg/confer"
+	"goframe/pkg/util"
+	"log"
+	"math/rand"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+)
+
+const (
+	defaultSlowThreshold = 200 * time.Millisecond
+	// 统计的sql指纹上限，超出后计入otherFingerprint
+	maxFingerprints  = 1000
+	otherFingerprint = "(other)"
+	// 每个指纹保留最近的耗时用于计算分位数
+	latencySamples    = 512
+	maxFingerprintLen = 1024
+)
+
+// queryLogger gorm日志：错误及慢查询写入日志，普通查询按比例抽样，同时按指纹统计，
+// 开发环境同时输出全部sql到标准输出
+type queryLogger struct {
+	datasource string
+	slow       time.Duration
+	sampleRate float64
+	stats      bool
+	dev        glogger.Interface
+}
+
+func newQueryLogger(datasource string) glogger.Interface {
+	conf := confer.GetGlobalConfig().Mysql.Log
+	l := &queryLogger{
+		datasource: datasource,
+		slow:       time.Duration(conf.SlowThreshold) * time.Millisecond,
+		sampleRate: conf.SampleRate,
+		stats:      conf.Stats,
+	}
+	if l.slow <= 0 {
+		l.slow = defaultSlowThreshold
+	}
+	if confer.ConfigEnvIsDev() {
+		l.dev = glogger.New(
+			log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
+			glogger.Config{
+				SlowThreshold: l.slow,       // 慢 SQL 阈值
+				LogLevel:      glogger.Info, // Log level
+				Colorful:      true,         // 禁用彩色打印
+			},
+		)
+	}
+	return l
+}
+
+func (l *queryLogger) LogMode(level glogger.LogLevel) glogger.Interface {
+	nl := *l
+	if l.dev != nil {
+		nl.dev = l.dev.LogMode(level)
+	}
+	return &nl
+}
+
+func (l *queryLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.dev != nil {
+		l.dev.Info(ctx, msg, data...)
+	}
+}
+
+func (l *queryLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	logger.Warnf("mysql %s [%s] "+msg, append([]interface{}{l.datasource, requestID(ctx)}, data...)...)
+}
+
+func (l *queryLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	logger.Errorf("mysql %s [%s] "+msg, append([]interface{}{l.datasource, requestID(ctx)}, data...)...)
+}
+
+func (l *queryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	if l.dev != nil {
+		l.dev.Trace(ctx, begin, fc, err)
+	}
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	slow := elapsed > l.slow
+	sampled := !failed && !slow && l.sampleRate > 0 && rand.Float64() < l.sampleRate
+	if !failed && !slow && !sampled && !l.stats {
+		return
+	}
+	sqlStr, rows := fc()
+	fp, args := normalize(sqlStr)
+	if l.stats {
+		recordQuery(l.datasource, fp, elapsed, rows, failed, slow)
+	}
+	// fc()返回的sql中已填入参数值，非开发环境只记录指纹及参数个数，避免参数中的个人信息写入日志
+	logged := sqlStr
+	if l.dev == nil {
+		logged = fmt.Sprintf("%s args:%d", fp, args)
+	}
+	ms := float64(elapsed.Microseconds()) / 1000
+	switch {
+	case failed:
+		logger.Errorf("mysql %s [%s] %.3fms rows:%d %s error: %v", l.datasource, requestID(ctx), ms, rows, logged, err)
+	case slow:
+		logger.Warnf("mysql %s [%s] slow query %.3fms rows:%d %s", l.datasource, requestID(ctx), ms, rows, logged)
+	case sampled:
+		logger.Infof("mysql %s [%s] %.3fms rows:%d %s", l.datasource, requestID(ctx), ms, rows, logged)
+	}
+}
+
+func requestID(ctx context.Context) string {
+	if id := util.RequestID(ctx); id != "" {
+		return id
+	}
+	return "-"
+}
+
+// QueryStat 一种sql(指纹相同)的统计，耗时单位毫秒，分位数按最近的512次计算
+type QueryStat struct {
+	Datasource  string  `json:"datasource"`
+	Fingerprint string  `json:"fingerprint"`
+	Count       int64   `json:"count"`
+	Errors      int64   `json:"errors"`
+	Slow        int64   `json:"slow"`
+	Rows        int64   `json:"rows"`
+	TotalMs     float64 `json:"totalMs"`
+	AvgMs       float64 `json:"avgMs"`
+	P50Ms       float64 `json:"p50Ms"`
+	P99Ms       float64 `json:"p99Ms"`
+	MaxMs       float64 `json:"maxMs"`
+}
+
+type queryStat struct {
+	mu       sync.Mutex
+	count    int64
+	errors   int64
+	slow     int64
+	rows     int64
+	total    time.Duration
+	max      time.Duration
+	samples  [latencySamples]time.Duration
+	nSamples int
+}
+
+type statKey struct {
+	datasource  string
+	fingerprint string
+}
+
+var (
+	queryStatsMu sync.RWMutex
+	queryStats   = map[statKey]*queryStat{}
+)
+
+func recordQuery(datasource, fp string, elapsed time.Duration, rows int64, failed, slow bool) {
+	key := statKey{datasource, fp}
+	queryStatsMu.RLock()
+	s := queryStats[key]
+	queryStatsMu.RUnlock()
+	if s == nil {
+		queryStatsMu.Lock()
+		if s = queryStats[key]; s == nil {
+			if len(queryStats) >= maxFingerprints {
+				key.fingerprint = otherFingerprint
+			}
+			if s = queryStats[key]; s == nil {
+				s = &queryStat{}
+				queryStats[key] = s
+			}
+		}
+		queryStatsMu.Unlock()
+	}
+
+	s.mu.Lock()
+	s.samples[s.count%latencySamples] = elapsed
+	s.count++
+	if s.nSamples < latencySamples {
+		s.nSamples++
+	}
+	s.total += elapsed
+	if elapsed > s.max {
+		s.max = elapsed
+	}
+	if rows > 0 {
+		s.rows += rows
+	}
+	if failed {
+		s.errors++
+	}
+	if slow {
+		s.slow++
+	}
+	s.mu.Unlock()
+}
+
+// QueryStats 按总耗时倒序的sql统计
+func QueryStats() []QueryStat {
+	queryStatsMu.RLock()
+	result := make([]QueryStat, 0, len(queryStats))
+	for key, s := range queryStats {
+		result = append(result, s.snapshot(key))
+	}
+	queryStatsMu.RUnlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].TotalMs > result[j].TotalMs
+	})
+	return result
+}
+
+// ResetQueryStats 清空sql统计
+func ResetQueryStats() {
+	queryStatsMu.Lock()
+	queryStats = map[statKey]*queryStat{}
+	queryStatsMu.Unlock()
+}
+
+func (s *queryStat) snapshot(key statKey) QueryStat {
+	s.mu.Lock()
+	samples := make([]time.Duration, s.nSamples)
+	copy(samples, s.samples[:s.nSamples])
+	stat := QueryStat{
+		Datasource:  key.datasource,
+		Fingerprint: key.fingerprint,
+		Count:       s.count,
+		Errors:      s.errors,
+		Slow:        s.slow,
+		Rows:        s.rows,
+		TotalMs:     millis(s.total),
+		MaxMs:       millis(s.max),
+	}
+	s.mu.Unlock()
+	if stat.Count > 0 {
+		stat.AvgMs = stat.TotalMs / float64(stat.Count)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	stat.P50Ms = millis(percentile(samples, 0.50))
+	stat.P99Ms = millis(percentile(samples, 0.99))
+	return stat
+}
+
+// percentile 已排序样本的分位数(nearest-rank)
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.999999) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+var (
+	placeholderList = regexp.MustCompile(`\?(\s*,\s*\?)+`)
+	valuesList      = regexp.MustCompile(`(?i)(VALUES\s*\([^()]*\))(\s*,\s*\([^()]*\))+`)
+)
+
+// fingerprint 去掉sql中的字面量，参数个数不同的IN及多行VALUES视为同一种sql，
+// 如 SELECT * FROM `user` WHERE id IN (1,2,3) AND name = 'a' => SELECT * FROM `user` WHERE id IN (?) AND name = ?
+func fingerprint(sqlStr string) string {
+	fp, _ := normalize(sqlStr)
+	return fp
+}
+
+// normalize sql指纹及去掉的字面量个数
+func normalize(sqlStr string) (string, int) {
+	literals := 0
+	var b strings.Builder
+	b.Grow(len(sqlStr))
+	space := false
+	for i := 0; i < len(sqlStr); i++ {
+		ch := sqlStr[i]
+		if ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		switch {
+		case ch == '\'' || ch == '"':
+			i = skipQuoted(sqlStr, i)
+			b.WriteByte('?')
+			literals++
+		case ch == '`':
+			j := strings.IndexByte(sqlStr[i+1:], '`')
+			if j < 0 {
+				b.WriteString(sqlStr[i:])
+				i = len(sqlStr)
+			} else {
+				b.WriteString(sqlStr[i : i+j+2])
+				i += j + 1
+			}
+		case isDigit(ch) && !afterIdent(b.String()):
+			for i+1 < len(sqlStr) && (isIdent(sqlStr[i+1]) || sqlStr[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+			literals++
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	fp := placeholderList.ReplaceAllString(b.String(), "?")
+	fp = valuesList.ReplaceAllString(fp, "$1")
+	if len(fp) > maxFingerprintLen {
+		fp = fp[:maxFingerprintLen]
+	}
+	return fp, literals
+}
+
+// skipQuoted 返回字符串结束引号的位置，支持反斜杠及连续两个引号转义
+func skipQuoted(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(s)
+}
+
+func afterIdent(s string) bool {
+	return s != "" && isIdent(s[len(s)-1])
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdent(ch byte) bool {
+	return isDigit(ch) || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_' || ch == '$'
+}
+
+// explained 已执行过EXPLAIN的sql指纹
+var explained sync.Map
+
+// registerExplain 开发环境查询后执行EXPLAIN，每种sql只执行一次，全表扫描时记录warn日志
+func registerExplain(db *gorm.DB, name string) error {
+	if !confer.ConfigEnvIsDev() || !confer.GetGlobalConfig().Mysql.Log.Explain {
+		return nil
+	}
+	return db.Callback().Query().After("gorm:query").Register("goframe:explain", func(tx *gorm.DB) {
+		explain(tx, name)
+	})
+}
+
+func explain(tx *gorm.DB, name string) {
+	if tx.Error != nil || tx.DryRun || tx.Statement.SQL.Len() == 0 {
+		return
+	}
+	sqlStr := tx.Statement.SQL.String()
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(sqlStr)), "SELECT") {
+		return
+	}
+	if _, loaded := explained.LoadOrStore(statKey{name, fingerprint(sqlStr)}, true); loaded {
+		return
+	}
+	ctx := tx.Statement.Context
+	rows, err := tx.Statement.ConnPool.QueryContext(ctx, "EXPLAIN "+sqlStr, tx.Statement.Vars...)
+	if err != nil {
+		logger.Warnf("mysql %s explain error: %v", name, err)
+		return
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return
+		}
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			row[column] = values[i].String
+		}
+		if row["type"] == "ALL" {
+			logger.Warnf("mysql %s [%s] full table scan on %s (rows: %s): %s", name, requestID(ctx), row["table"],
+				row["rows"], tx.Dialector.Explain(sqlStr, tx.Statement.Vars...))
+		}
+	}
+}
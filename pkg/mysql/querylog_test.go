@@ -0,0 +1,50 @@
+package mysql
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		sql      string
+		want     string
+		literals int
+	}{
+		{"SELECT * FROM `user` WHERE id IN (1,2,3) AND name = 'a'", "SELECT * FROM `user` WHERE id IN (?) AND name = ?", 4},
+		{"SELECT *  FROM\n\t`user`   WHERE id = 10", "SELECT * FROM `user` WHERE id = ?", 1},
+		{"SELECT * FROM `t1` WHERE `col2` = 1.5e3", "SELECT * FROM `t1` WHERE `col2` = ?", 1},
+		{"SELECT * FROM t1 WHERE a = -1", "SELECT * FROM t1 WHERE a = -?", 1},
+		{`SELECT * FROM user WHERE name = 'it''s' AND note = "a \" b"`, "SELECT * FROM user WHERE name = ? AND note = ?", 2},
+		{`SELECT * FROM user WHERE name = 'a\'b' AND id = 2`, "SELECT * FROM user WHERE name = ? AND id = ?", 2},
+		{"INSERT INTO `user` (`name`,`age`) VALUES ('a',1),('b',NULL),('c',3)", "INSERT INTO `user` (`name`,`age`) VALUES (?)", 5},
+		{"SELECT * FROM `user` WHERE id IN (?,?,?)", "SELECT * FROM `user` WHERE id IN (?)", 0},
+		{"SELECT * FROM `user 1` LIMIT 10", "SELECT * FROM `user 1` LIMIT ?", 1},
+		{"SELECT * FROM user WHERE name = 'unterminated", "SELECT * FROM user WHERE name = ?", 1},
+	}
+	for _, tt := range tests {
+		got, literals := normalize(tt.sql)
+		if got != tt.want || literals != tt.literals {
+			t.Errorf("normalize(%q) = %q, %d, want %q, %d", tt.sql, got, literals, tt.want, tt.literals)
+		}
+		if fp := fingerprint(tt.sql); fp != got {
+			t.Errorf("fingerprint(%q) = %q, want %q", tt.sql, fp, got)
+		}
+	}
+}
+
+func TestSkipQuoted(t *testing.T) {
+	tests := []struct {
+		s     string
+		start int
+		want  int
+	}{
+		{`'abc' x`, 0, 4},
+		{`x = 'a''b' y`, 4, 9},
+		{`'a\'b'`, 0, 5},
+		{`"a'b"`, 0, 4},
+		{`'abc`, 0, 4},
+	}
+	for _, tt := range tests {
+		if got := skipQuoted(tt.s, tt.start); got != tt.want {
+			t.Errorf("skipQuoted(%q, %d) = %d, want %d", tt.s, tt.start, got, tt.want)
+		}
+	}
+}
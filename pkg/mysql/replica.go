@@ -0,0 +1,221 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"goframe/pkg/confer"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+const (
+	BalanceRoundRobin = "round-robin"
+	BalanceWeighted   = "weighted"
+	BalanceLeastConn  = "least-conn"
+
+	defaultHealthCheckInterval = 5
+	healthCheckTimeout         = 2 * time.Second
+)
+
+// replica 单个读库，健康检查失败时暂时剔除
+type replica struct {
+	addr    string
+	db      *sql.DB
+	weight  int
+	healthy atomic.Bool
+}
+
+// replicaSet 读库集合，读orm的每条查询在执行前按负载均衡选择读库
+type replicaSet struct {
+	name     string
+	replicas []*replica
+	balance  string
+	next     uint64
+	writer   gorm.ConnPool
+}
+
+// initReplicas 连接数据源的全部读库，连接失败的读库标记为不可用，由健康检查恢复
+func initReplicas(ds *datasource, conf confer.MysqlSource) error {
+	if len(conf.Reads) == 0 {
+		return nil
+	}
+	writer, err := ds.write.DB.DB()
+	if err != nil {
+		return err
+	}
+	set := &replicaSet{name: ds.name, balance: conf.ReadBalance, writer: writer}
+	for _, read := range conf.Reads {
+		if read.Host == "" || read.DBName == "" {
+			return errors.New("read dbConfig is null")
+		}
+		db, err := sql.Open("mysql", dsn(read))
+		if err != nil {
+			return err
+		}
+		setPool(db, conf.Pool)
+		r := &replica{addr: fmt.Sprintf("%s:%d", read.Host, read.Port), db: db, weight: read.Weight}
+		if r.weight <= 0 {
+			r.weight = 1
+		}
+		set.replicas = append(set.replicas, r)
+		if err = r.ping(); err != nil {
+			logger.Errorf("mysql %s replica %s is unavailable: %v", ds.name, r.addr, err)
+		}
+		r.healthy.Store(err == nil)
+	}
+
+	config := gormConfig(ds.name, conf)
+	config.DisableAutomaticPing = true
+	readDB, err := gorm.Open(mysql.New(mysql.Config{Conn: set.replicas[0].db, SkipInitializeWithVersion: true}), config)
+	if err != nil {
+		return err
+	}
+	if err = set.register(readDB); err != nil {
+		return err
+	}
+	if err = registerPoolLimit(readDB); err != nil {
+		return err
+	}
+	if err = registerExplain(readDB, ds.name); err != nil {
+		return err
+	}
+	datasourcesMu.Lock()
+	ds.read = MysqlConnection{DB: readDB, IsRead: true}
+	ds.replicas = set
+	datasourcesMu.Unlock()
+
+	interval := conf.HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	go set.healthCheck(time.Duration(interval) * time.Second)
+	return nil
+}
+
+// register 查询走读库，误用读orm执行的写操作转到写库
+func (s *replicaSet) register(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("goframe:replica", s.routeRead); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("goframe:replica", s.routeRead); err != nil {
+		return err
+	}
+	if err := cb.Create().Before("gorm:create").Register("goframe:replica", s.routeWrite); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("goframe:replica", s.routeWrite); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("goframe:replica", s.routeWrite); err != nil {
+		return err
+	}
+	return cb.Raw().Before("gorm:raw").Register("goframe:replica", s.routeWrite)
+}
+
+func (s *replicaSet) routeRead(db *gorm.DB) {
+	if inTransaction(db) {
+		return
+	}
+	if hasWritten(db.Statement.Context, s.name) {
+		db.Statement.ConnPool = s.writer
+		return
+	}
+	if r := s.pick(); r != nil {
+		db.Statement.ConnPool = r.db
+	} else {
+		db.Statement.ConnPool = s.writer
+	}
+}
+
+func (s *replicaSet) routeWrite(db *gorm.DB) {
+	if inTransaction(db) {
+		return
+	}
+	db.Statement.ConnPool = s.writer
+	markWritten(db, s.name)
+}
+
+// inTransaction 事务内的语句使用事务所在的连接
+func inTransaction(db *gorm.DB) bool {
+	_, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok
+}
+
+// pick 按负载均衡策略选择可用读库，全部不可用时返回nil
+func (s *replicaSet) pick() *replica {
+	healthy := make([]*replica, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	n := atomic.AddUint64(&s.next, 1)
+	switch s.balance {
+	case BalanceWeighted:
+		total := 0
+		for _, r := range healthy {
+			total += r.weight
+		}
+		w := rand.Intn(total)
+		for _, r := range healthy {
+			if w -= r.weight; w < 0 {
+				return r
+			}
+		}
+		return healthy[0]
+	case BalanceLeastConn:
+		// 从轮询位置开始比较，使用中连接数相同时分散到不同读库
+		var best *replica
+		bestInUse := 0
+		for i := range healthy {
+			r := healthy[(int(n)+i)%len(healthy)]
+			if inUse := r.db.Stats().InUse; best == nil || inUse < bestInUse {
+				best, bestInUse = r, inUse
+			}
+		}
+		return best
+	default:
+		return healthy[int(n%uint64(len(healthy)))]
+	}
+}
+
+func (s *replicaSet) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, r := range s.replicas {
+			s.check(r)
+		}
+	}
+}
+
+// check 状态变化时记录日志
+func (s *replicaSet) check(r *replica) {
+	err := r.ping()
+	healthy := err == nil
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		logger.Infof("mysql %s replica %s is healthy, added back", s.name, r.addr)
+	} else {
+		logger.Errorf("mysql %s replica %s is unhealthy, ejected: %v", s.name, r.addr, err)
+	}
+}
+
+func (r *replica) ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	return r.db.PingContext(ctx)
+}
@@ -0,0 +1,488 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goframe/constv"
+	"goframe/pkg/query"
+	"goframe/pkg/response"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultBatchSize = 500
+
+// ErrVersionConflict 乐观锁更新时数据已被其他请求修改
+var ErrVersionConflict = response.NewCodeError(constv.CODE_COMMON_DATA_VERSION_CONFLICT)
+
+// ErrShardKeyChanged Update修改了分片表记录的分片键，需删除后按新的分片键重新创建
+var ErrShardKeyChanged = response.NewCodeError(constv.CODE_COMMON_PARAMS_INCOMPLETE, "分片键不能修改")
+
+// Model 通用字段，嵌入到表结构中使用：创建及更新时间由gorm自动维护，Delete为软删除。
+// 需要乐观锁时在表结构中增加整数类型的Version字段。
+type Model struct {
+	ID        uint64         `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Scope 查询条件
+type Scope = func(db *gorm.DB) *gorm.DB
+
+// Where 条件，参数同gorm的Where
+func Where(query interface{}, args ...interface{}) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(query, args...)
+	}
+}
+
+// WithTrashed 查询包含已软删除的记录
+func WithTrashed() Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}
+}
+
+// Repository 表T的通用数据访问，查询走读库，写操作走写库，
+// 上下文中有事务(WithTx)时全部使用事务，DaoMysql.TableName不为空时替代T的表名。
+// 表配置了mysql.sharding时按条件或记录中的分片键路由到分片，没有分片键的语句需allow-scatter或Scatter()
+type Repository[T any] struct {
+	*DaoMysql
+}
+
+// NewRepository 不传名称时使用默认数据源
+func NewRepository[T any](datasource ...string) *Repository[T] {
+	return &Repository[T]{DaoMysql: NewDaoMysql(datasource...)}
+}
+
+func (r *Repository[T]) read(ctx context.Context) *gorm.DB {
+	return r.table(r.ReadOrm(ctx))
+}
+
+func (r *Repository[T]) write(ctx context.Context) *gorm.DB {
+	return r.table(r.Orm(ctx))
+}
+
+func (r *Repository[T]) table(db *gorm.DB) *gorm.DB {
+	if r.TableName != "" {
+		return db.Table(r.TableName)
+	}
+	return db
+}
+
+// Get 按主键查询，不存在时返回gorm.ErrRecordNotFound
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	return r.First(ctx, byID(id))
+}
+
+// First 第一条符合条件的记录，按主键排序
+func (r *Repository[T]) First(ctx context.Context, scopes ...Scope) (*T, error) {
+	dbs, err := r.targets(ctx, false, scopes)
+	if err != nil {
+		return nil, err
+	}
+	if len(dbs) == 1 {
+		var m T
+		if err = dbs[0].Scopes(scopes...).First(&m).Error; err != nil {
+			return nil, err
+		}
+		return &m, nil
+	}
+	// 多个分片时取主键最小的记录
+	var first *T
+	var firstID interface{}
+	for _, db := range dbs {
+		var m T
+		err = db.Scopes(scopes...).First(&m).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if id := primaryValue(db, &m); first == nil || query.Compare(id, firstID) < 0 {
+			first, firstID = &m, id
+		}
+	}
+	if first == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return first, nil
+}
+
+// Find 全部符合条件的记录，分片表依次合并各分片的结果
+func (r *Repository[T]) Find(ctx context.Context, scopes ...Scope) ([]T, error) {
+	dbs, err := r.targets(ctx, false, scopes)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]T, 0)
+	for _, db := range dbs {
+		items := make([]T, 0)
+		if err = db.Scopes(scopes...).Find(&items).Error; err != nil {
+			return nil, err
+		}
+		list = append(list, items...)
+	}
+	return list, nil
+}
+
+// Count 符合条件的记录数
+func (r *Repository[T]) Count(ctx context.Context, scopes ...Scope) (int64, error) {
+	dbs, err := r.targets(ctx, false, scopes)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, db := range dbs {
+		var count int64
+		if err = db.Model(new(T)).Scopes(scopes...).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// Exists 是否有符合条件的记录
+func (r *Repository[T]) Exists(ctx context.Context, scopes ...Scope) (bool, error) {
+	dbs, err := r.targets(ctx, false, scopes)
+	if err != nil {
+		return false, err
+	}
+	for _, db := range dbs {
+		var found []int
+		if err = db.Model(new(T)).Scopes(scopes...).Select("1").Limit(1).Find(&found).Error; err != nil {
+			return false, err
+		}
+		if len(found) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Paginate 分页查询，参数由query.Parse解析，分片表的过滤参数中有分片键时只查询对应分片，
+// 跨分片时只能按数字、时间列及主键排序
+func (r *Repository[T]) Paginate(ctx context.Context, p *query.Params, scopes ...Scope) (*response.Page[T], error) {
+	dbs, err := r.targets(ctx, false, append(scopes[:len(scopes):len(scopes)], func(db *gorm.DB) *gorm.DB { return query.Where(db, p) }))
+	if err != nil {
+		return nil, err
+	}
+	for i, db := range dbs {
+		dbs[i] = db.Model(new(T)).Scopes(scopes...)
+	}
+	return query.PaginateShards[T](dbs, p)
+}
+
+// Create 创建记录，自增主键及创建时间回写到entity，分片表按entity的分片键写入对应分片
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	db, err := r.entityTarget(ctx, entity)
+	if err != nil {
+		return err
+	}
+	return db.Create(entity).Error
+}
+
+// CreateBatch 按size分批插入，size<=0时每批500条，全部批次在同一事务中；
+// 分片表按分片分组，每个分片一个事务，不同数据源之间不保证原子性
+func (r *Repository[T]) CreateBatch(ctx context.Context, entities []*T, size int) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	s := r.sharder()
+	if s == nil {
+		return r.WithTx(ctx, func(ctx context.Context) error {
+			return r.write(ctx).CreateInBatches(entities, size).Error
+		}, WithRetries(0))
+	}
+	groups := map[int][]*T{}
+	shards := map[int]Shard{}
+	for _, entity := range entities {
+		shard, err := r.locate(ctx, s, entity)
+		if err != nil {
+			return err
+		}
+		groups[shard.Index] = append(groups[shard.Index], entity)
+		shards[shard.Index] = shard
+	}
+	for _, shard := range s.Shards() {
+		group, ok := groups[shard.Index]
+		if !ok {
+			continue
+		}
+		dao := r.shardDao(shard)
+		err := dao.WithTx(ctx, func(ctx context.Context) error {
+			return dao.Orm(ctx).Table(shard.Table).CreateInBatches(group, size).Error
+		}, WithRetries(0))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Upsert 主键或唯一索引冲突时更新，columns为空时更新全部字段
+func (r *Repository[T]) Upsert(ctx context.Context, entity *T, columns ...string) error {
+	db, err := r.entityTarget(ctx, entity)
+	if err != nil {
+		return err
+	}
+	onConflict := clause.OnConflict{UpdateAll: true}
+	if len(columns) > 0 {
+		onConflict = clause.OnConflict{DoUpdates: clause.AssignmentColumns(columns)}
+	}
+	return db.Clauses(onConflict).Create(entity).Error
+}
+
+// Update 按主键更新全部字段(包括零值，不含创建时间)，
+// 有Version字段时使用乐观锁：版本不一致返回ErrVersionConflict，成功后entity.Version加1；
+// 分片表的分片键与库中记录不一致时返回ErrShardKeyChanged
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	db, err := r.entityTarget(ctx, entity)
+	if err != nil {
+		return err
+	}
+	if s := r.sharder(); s != nil {
+		if err = r.checkShardKey(ctx, s, db, entity); err != nil {
+			return err
+		}
+	}
+	db = db.Model(entity)
+	if err = db.Statement.Parse(entity); err != nil {
+		return err
+	}
+	sch := db.Statement.Schema
+	var omit []string
+	if field := sch.LookUpField("CreatedAt"); field != nil {
+		omit = append(omit, field.DBName)
+	}
+	version := sch.LookUpField("Version")
+	if version == nil {
+		return db.Select("*").Omit(omit...).Updates(entity).Error
+	}
+
+	fv := version.ReflectValueOf(ctx, reflect.ValueOf(entity).Elem())
+	old := reflect.ValueOf(fv.Interface())
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fv.SetInt(fv.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		fv.SetUint(fv.Uint() + 1)
+	default:
+		return fmt.Errorf("mysql: version field of %s must be an integer", sch.Name)
+	}
+	tx := db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: version.DBName}, Value: old.Interface()}).
+		Select("*").Omit(omit...).Updates(entity)
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		tx.Error = ErrVersionConflict
+	}
+	if tx.Error != nil {
+		fv.Set(old)
+	}
+	return tx.Error
+}
+
+// checkShardKey 按主键读取entity分片中的记录，分片键不同或记录在其他分片时返回ErrShardKeyChanged，
+// 记录不存在时不处理(与未分片的表一致，更新0行)。分片键为主键时不检查
+func (r *Repository[T]) checkShardKey(ctx context.Context, s *Sharder, db *gorm.DB, entity *T) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(entity); err != nil {
+		return err
+	}
+	key, pk := stmt.Schema.LookUpField(s.Key()), stmt.Schema.PrioritizedPrimaryField
+	if key == nil || pk == nil || key.PrimaryKey {
+		return nil
+	}
+	id, zero := pk.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	if zero {
+		return nil
+	}
+	value, _ := key.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	current := new(T)
+	tx := db.Session(&gorm.Session{}).Select(key.DBName).Scopes(byID(id)).Limit(1).Find(current)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		old, _ := key.ValueOf(ctx, reflect.ValueOf(current).Elem())
+		if !reflect.DeepEqual(old, value) {
+			return ErrShardKeyChanged
+		}
+		return nil
+	}
+	// 不在当前分片：分片键已改为其他分片的值
+	for _, shard := range s.Shards() {
+		var count int64
+		err := r.shardDB(ctx, shard, true).Model(new(T)).Scopes(byID(id)).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrShardKeyChanged
+		}
+	}
+	return nil
+}
+
+// UpdateFields 按主键更新指定字段，values的key为列名，
+// 分片表的分片键不是主键时需allow-scatter
+func (r *Repository[T]) UpdateFields(ctx context.Context, id interface{}, values map[string]interface{}) error {
+	return r.each(ctx, []Scope{byID(id)}, func(db *gorm.DB) error {
+		return db.Model(new(T)).Scopes(byID(id)).Updates(values).Error
+	})
+}
+
+// Delete 按主键删除，有DeletedAt字段时为软删除
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	return r.each(ctx, []Scope{byID(id)}, func(db *gorm.DB) error {
+		return db.Scopes(byID(id)).Delete(new(T)).Error
+	})
+}
+
+// ForceDelete 按主键物理删除
+func (r *Repository[T]) ForceDelete(ctx context.Context, id interface{}) error {
+	return r.each(ctx, []Scope{byID(id)}, func(db *gorm.DB) error {
+		return db.Unscoped().Scopes(byID(id)).Delete(new(T)).Error
+	})
+}
+
+// each 在语句涉及的每个分片的写库上执行fn
+func (r *Repository[T]) each(ctx context.Context, scopes []Scope, fn func(db *gorm.DB) error) error {
+	dbs, err := r.targets(ctx, true, scopes)
+	if err != nil {
+		return err
+	}
+	for _, db := range dbs {
+		if err = fn(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sharder T的表配置了分片时返回分片规则
+func (r *Repository[T]) sharder() *Sharder {
+	if !hasSharding() {
+		return nil
+	}
+	table := r.TableName
+	if table == "" {
+		db := r.GetWriteOrm().DB
+		if db == nil {
+			return nil
+		}
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(new(T)); err != nil {
+			return nil
+		}
+		table = stmt.Schema.Table
+	}
+	return ShardingOf(table)
+}
+
+// targets 语句执行的库表，分片表按scopes中分片键的条件路由到一个或多个分片
+func (r *Repository[T]) targets(ctx context.Context, write bool, scopes []Scope) ([]*gorm.DB, error) {
+	s := r.sharder()
+	if s == nil {
+		if write {
+			return []*gorm.DB{r.write(ctx)}, nil
+		}
+		return []*gorm.DB{r.read(ctx)}, nil
+	}
+	probe := r.GetWriteOrm().DB.Session(&gorm.Session{NewDB: true, DryRun: true}).Model(new(T))
+	for _, scope := range scopes {
+		probe = scope(probe)
+	}
+	if err := probe.Statement.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	shards, err := s.route(probe)
+	if err != nil {
+		return nil, err
+	}
+	dbs := make([]*gorm.DB, len(shards))
+	for i, shard := range shards {
+		dbs[i] = r.shardDB(ctx, shard, write)
+	}
+	return dbs, nil
+}
+
+// entityTarget 写入entity的库表，分片表按entity的分片键选择分片
+func (r *Repository[T]) entityTarget(ctx context.Context, entity *T) (*gorm.DB, error) {
+	s := r.sharder()
+	if s == nil {
+		return r.write(ctx), nil
+	}
+	shard, err := r.locate(ctx, s, entity)
+	if err != nil {
+		return nil, err
+	}
+	return r.shardDB(ctx, shard, true), nil
+}
+
+// locate entity所在的分片，分片键为零值时返回ErrShardKeyRequired，自增主键不能作为分片键
+func (r *Repository[T]) locate(ctx context.Context, s *Sharder, entity *T) (Shard, error) {
+	stmt := &gorm.Statement{DB: r.GetWriteOrm().DB}
+	if err := stmt.Parse(entity); err != nil {
+		return Shard{}, err
+	}
+	field := stmt.Schema.LookUpField(s.Key())
+	if field == nil {
+		return Shard{}, fmt.Errorf("mysql sharding %s: %s has no shard key field %s", s.Table(), stmt.Schema.Name, s.Key())
+	}
+	value, zero := field.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	if zero {
+		return Shard{}, fmt.Errorf("%w, %s.%s is empty", ErrShardKeyRequired, stmt.Schema.Name, field.Name)
+	}
+	return s.Locate(value)
+}
+
+// shardDao 分片所在数据源的dao，分片规则未配置数据源时使用Repository的数据源
+func (r *Repository[T]) shardDao(shard Shard) *DaoMysql {
+	if shard.Datasource == "" {
+		return NewDaoMysql(r.Datasource)
+	}
+	return NewDaoMysql(shard.Datasource)
+}
+
+// shardDB 分片的读库或写库(上下文中有该数据源的事务时使用事务)
+func (r *Repository[T]) shardDB(ctx context.Context, shard Shard, write bool) *gorm.DB {
+	dao := r.shardDao(shard)
+	if write {
+		return dao.Orm(ctx).Table(shard.Table)
+	}
+	return dao.ReadOrm(ctx).Table(shard.Table)
+}
+
+// primaryValue 记录的主键值，用于合并多个分片的First结果
+func primaryValue(db *gorm.DB, entity interface{}) interface{} {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(entity); err != nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil
+	}
+	v, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+	return v
+}
+
+// byID 主键条件，id为切片时使用IN
+func byID(id interface{}) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if rv := reflect.ValueOf(id); rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+			values := make([]interface{}, rv.Len())
+			for i := range values {
+				values[i] = rv.Index(i).Interface()
+			}
+			return db.Where(clause.IN{Column: clause.PrimaryColumn, Values: values})
+		}
+		return db.Where(clause.Eq{Column: clause.PrimaryColumn, Value: id})
+	}
+}
@@ -0,0 +1,436 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"goframe/pkg/confer"
+	"hash/crc32"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	ShardingMod   = "mod"
+	ShardingRange = "range"
+	ShardingHash  = "hash"
+
+	defaultVirtualNodes = 160
+
+	scatterKey  = "goframe:scatter"
+	shardKeyKey = "goframe:shard_key"
+)
+
+// ErrShardKeyRequired 分片表的语句没有分片键条件且不允许扫描全部分片，或写入的记录分片键为零值
+var ErrShardKeyRequired = errors.New("mysql: shard key is required")
+
+// Shard 分片所在的数据源及表，Datasource为空表示使用dao的数据源
+type Shard struct {
+	Index      int    `json:"index"`
+	Datasource string `json:"datasource"`
+	Table      string `json:"table"`
+}
+
+// Sharder 一张表的分片规则
+type Sharder struct {
+	table string
+	rule  confer.Sharding
+	count int
+	ring  []ringNode
+}
+
+// ringNode 一致性哈希环上的虚拟节点
+type ringNode struct {
+	hash  uint32
+	shard int
+}
+
+// NewSharder 校验分片规则
+func NewSharder(table string, rule confer.Sharding) (*Sharder, error) {
+	if rule.Key == "" {
+		return nil, fmt.Errorf("mysql sharding %s: key is required", table)
+	}
+	if rule.Strategy == "" {
+		rule.Strategy = ShardingMod
+	}
+	if rule.Tables <= 0 {
+		rule.Tables = 1
+	}
+	s := &Sharder{table: table, rule: rule, count: rule.Tables}
+	if len(rule.Datasources) > 0 {
+		s.count *= len(rule.Datasources)
+	}
+	switch rule.Strategy {
+	case ShardingMod:
+	case ShardingRange:
+		if len(rule.Ranges) != s.count-1 {
+			return nil, fmt.Errorf("mysql sharding %s: %d ranges required for %d shards", table, s.count-1, s.count)
+		}
+		for i := 1; i < len(rule.Ranges); i++ {
+			if rule.Ranges[i] <= rule.Ranges[i-1] {
+				return nil, fmt.Errorf("mysql sharding %s: ranges must be ascending", table)
+			}
+		}
+	case ShardingHash:
+		nodes := rule.VirtualNodes
+		if nodes <= 0 {
+			nodes = defaultVirtualNodes
+		}
+		for i := 0; i < s.count; i++ {
+			for j := 0; j < nodes; j++ {
+				s.ring = append(s.ring, ringNode{hash: crc32.ChecksumIEEE([]byte(fmt.Sprintf("shard-%d#%d", i, j))), shard: i})
+			}
+		}
+		sort.Slice(s.ring, func(i, j int) bool { return s.ring[i].hash < s.ring[j].hash })
+	default:
+		return nil, fmt.Errorf("mysql sharding %s: unknown strategy %s", table, rule.Strategy)
+	}
+	return s, nil
+}
+
+// Table 逻辑表名
+func (s *Sharder) Table() string {
+	return s.table
+}
+
+// Key 分片键
+func (s *Sharder) Key() string {
+	return s.rule.Key
+}
+
+// AllowScatter 是否允许扫描全部分片
+func (s *Sharder) AllowScatter() bool {
+	return s.rule.AllowScatter
+}
+
+// Shards 全部分片
+func (s *Sharder) Shards() []Shard {
+	shards := make([]Shard, s.count)
+	for i := range shards {
+		shards[i] = s.shard(i)
+	}
+	return shards
+}
+
+// Locate 分片键的值所在的分片，值支持整数、字符串及[]byte，range只支持整数；
+// mod及hash按值的类型计算，整数列应传整数而不是字符串形式的整数
+func (s *Sharder) Locate(key interface{}) (Shard, error) {
+	index, err := s.index(key)
+	if err != nil {
+		return Shard{}, err
+	}
+	return s.shard(index), nil
+}
+
+func (s *Sharder) shard(index int) Shard {
+	shard := Shard{Index: index, Table: s.table}
+	if len(s.rule.Datasources) > 0 {
+		shard.Datasource = s.rule.Datasources[index/s.rule.Tables]
+	}
+	if s.rule.Tables > 1 {
+		shard.Table = fmt.Sprintf("%s_%d", s.table, index%s.rule.Tables)
+	}
+	return shard
+}
+
+func (s *Sharder) index(key interface{}) (int, error) {
+	k, err := shardValue(key)
+	if err != nil {
+		return 0, fmt.Errorf("mysql sharding %s: %w", s.table, err)
+	}
+	switch s.rule.Strategy {
+	case ShardingRange:
+		if !k.isInt {
+			if k, err = parseIntKey(k.str); err != nil {
+				return 0, fmt.Errorf("mysql sharding %s: range key must be an integer, got %q", s.table, k.str)
+			}
+		}
+		n := k.int64()
+		return sort.Search(len(s.rule.Ranges), func(i int) bool { return n < s.rule.Ranges[i] }), nil
+	case ShardingHash:
+		h := crc32.ChecksumIEEE([]byte(k.str))
+		i := sort.Search(len(s.ring), func(i int) bool { return s.ring[i].hash >= h })
+		if i == len(s.ring) {
+			i = 0
+		}
+		return s.ring[i].shard, nil
+	default:
+		if !k.isInt {
+			return int(crc32.ChecksumIEEE([]byte(k.str)) % uint32(s.count)), nil
+		}
+		return int(k.abs % uint64(s.count)), nil
+	}
+}
+
+// shardKey 分片键的字符串形式，整数时另存符号及绝对值，uint64超出int64范围时不溢出
+type shardKey struct {
+	str   string
+	isInt bool
+	neg   bool
+	abs   uint64
+}
+
+func intKey(n int64) shardKey {
+	if n < 0 {
+		return shardKey{str: strconv.FormatInt(n, 10), isInt: true, neg: true, abs: uint64(-(n + 1)) + 1}
+	}
+	return uintKey(uint64(n))
+}
+
+func uintKey(u uint64) shardKey {
+	return shardKey{str: strconv.FormatUint(u, 10), isInt: true, abs: u}
+}
+
+// parseIntKey 字符串形式的整数
+func parseIntKey(str string) (shardKey, error) {
+	if n, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return intKey(n), nil
+	}
+	u, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return shardKey{str: str}, err
+	}
+	return uintKey(u), nil
+}
+
+// int64 range比较使用的值，超出int64范围的uint64视为最大值
+func (k shardKey) int64() int64 {
+	if k.neg {
+		return -int64(k.abs)
+	}
+	if k.abs > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(k.abs)
+}
+
+// shardValue 分片键的值，支持整数、字符串及[]byte
+func shardValue(key interface{}) (shardKey, error) {
+	rv := reflect.ValueOf(key)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return shardKey{}, errors.New("shard key is nil")
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intKey(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintKey(rv.Uint()), nil
+	case reflect.String:
+		return shardKey{str: rv.String()}, nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return shardKey{str: string(rv.Bytes())}, nil
+		}
+	}
+	return shardKey{}, fmt.Errorf("unsupported shard key type %T", key)
+}
+
+// convertKey 条件中的值按分片键列的类型转换：query参数等字符串形式的整数转为整数，
+// 字符串列的整数值转为字符串，与写入时按记录字段计算的分片一致
+func convertKey(field *schema.Field, value interface{}) interface{} {
+	if field == nil {
+		return value
+	}
+	k, err := shardValue(value)
+	if err != nil {
+		return value
+	}
+	switch field.IndirectFieldType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !k.isInt {
+			str := strings.TrimSpace(k.str)
+			if n, err := strconv.ParseInt(str, 10, 64); err == nil {
+				return n
+			}
+			if u, err := strconv.ParseUint(str, 10, 64); err == nil {
+				return u
+			}
+		}
+	case reflect.String:
+		if k.isInt {
+			return k.str
+		}
+	}
+	return value
+}
+
+var (
+	shardersMu sync.RWMutex
+	sharders   = map[string]*Sharder{}
+)
+
+// InitSharding 加载分片规则，分片的数据源需已初始化
+func InitSharding(rules map[string]confer.Sharding) error {
+	loaded := make(map[string]*Sharder, len(rules))
+	for table, rule := range rules {
+		s, err := NewSharder(table, rule)
+		if err != nil {
+			return err
+		}
+		for _, name := range rule.Datasources {
+			if lookup(name) == nil {
+				return fmt.Errorf("mysql sharding %s: datasource %s is not initialized", table, name)
+			}
+		}
+		loaded[table] = s
+	}
+	shardersMu.Lock()
+	sharders = loaded
+	shardersMu.Unlock()
+	return nil
+}
+
+func hasSharding() bool {
+	shardersMu.RLock()
+	defer shardersMu.RUnlock()
+	return len(sharders) > 0
+}
+
+// ShardingOf 表的分片规则，未分片时返回nil
+func ShardingOf(table string) *Sharder {
+	shardersMu.RLock()
+	defer shardersMu.RUnlock()
+	return sharders[table]
+}
+
+// Scatter 分片表没有分片键条件时扫描全部分片，用于后台统计等少量查询
+func Scatter() Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(scatterKey, true)
+	}
+}
+
+// ShardKey 指定分片键的值，用于条件无法解析出分片键的查询，如join或子查询
+func ShardKey(values ...interface{}) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(shardKeyKey, values)
+	}
+}
+
+// route 语句涉及的分片：ShardKey指定的值，或where中分片键的 = 及 IN 条件，
+// 都没有时为全部分片，需allow-scatter或Scatter()
+func (s *Sharder) route(db *gorm.DB) ([]Shard, error) {
+	values, ok := shardKeyValues(db, s.rule.Key)
+	if !ok {
+		if v, scatter := db.Get(scatterKey); s.rule.AllowScatter || scatter && v == true {
+			return s.Shards(), nil
+		}
+		return nil, fmt.Errorf("%w, table %s has no %s condition, use mysql.ShardKey or mysql.Scatter()",
+			ErrShardKeyRequired, s.table, s.rule.Key)
+	}
+	seen := map[int]bool{}
+	var shards []Shard
+	var field *schema.Field
+	if db.Statement.Schema != nil {
+		field = db.Statement.Schema.LookUpField(s.rule.Key)
+	}
+	for _, v := range values {
+		shard, err := s.Locate(convertKey(field, v))
+		if err != nil {
+			return nil, err
+		}
+		if !seen[shard.Index] {
+			seen[shard.Index] = true
+			shards = append(shards, shard)
+		}
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].Index < shards[j].Index })
+	return shards, nil
+}
+
+// 解析 "user_id = ?"、"`order`.`user_id` IN ?" 形式的条件
+var keyExpr = regexp.MustCompile("(?i)^\\s*(?:`?\\w+`?\\.)?`?(\\w+)`?\\s*(=|in)\\s*\\(?\\s*\\?\\s*\\)?\\s*$")
+
+func shardKeyValues(db *gorm.DB, key string) ([]interface{}, bool) {
+	if v, ok := db.Get(shardKeyKey); ok {
+		var values []interface{}
+		for _, value := range v.([]interface{}) {
+			values = append(values, flatten(value)...)
+		}
+		return values, true
+	}
+	c, ok := db.Statement.Clauses["WHERE"]
+	if !ok {
+		return nil, false
+	}
+	where, ok := c.Expression.(clause.Where)
+	if !ok {
+		return nil, false
+	}
+	primary := ""
+	if db.Statement.Schema != nil && db.Statement.Schema.PrioritizedPrimaryField != nil {
+		primary = db.Statement.Schema.PrioritizedPrimaryField.DBName
+	}
+	return exprKeyValues(where.Exprs, key, primary)
+}
+
+// exprKeyValues 只解析AND连接的条件，OR、NOT中的条件不能确定分片
+func exprKeyValues(exprs []clause.Expression, key, primary string) ([]interface{}, bool) {
+	isKey := func(column interface{}) bool {
+		switch c := column.(type) {
+		case string:
+			return strings.Trim(c[strings.LastIndexByte(c, '.')+1:], "`") == key
+		case clause.Column:
+			if c.Name == clause.PrimaryKey {
+				return primary == key
+			}
+			return c.Name == key
+		}
+		return false
+	}
+	for _, expr := range exprs {
+		if _, ok := expr.(clause.OrConditions); ok {
+			return nil, false
+		}
+	}
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case clause.Eq:
+			if isKey(e.Column) {
+				return flatten(e.Value), true
+			}
+		case clause.IN:
+			if isKey(e.Column) {
+				return e.Values, true
+			}
+		case clause.Expr:
+			if m := keyExpr.FindStringSubmatch(e.SQL); m != nil && m[1] == key && len(e.Vars) == 1 {
+				return flatten(e.Vars[0]), true
+			}
+		case clause.AndConditions:
+			if values, ok := exprKeyValues(e.Exprs, key, primary); ok {
+				return values, true
+			}
+		case clause.Where:
+			if values, ok := exprKeyValues(e.Exprs, key, primary); ok {
+				return values, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// flatten IN条件的切片参数展开为多个值
+func flatten(v interface{}) []interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return []interface{}{v}
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
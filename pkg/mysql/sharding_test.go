@@ -0,0 +1,167 @@
+package mysql
+
+import (
+	"goframe/pkg/confer"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+func newTestSharder(t *testing.T, rule confer.Sharding) *Sharder {
+	t.Helper()
+	s, err := NewSharder("orders", rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSharderIndex(t *testing.T) {
+	mod := newTestSharder(t, confer.Sharding{Key: "user_id", Tables: 4})
+	rng := newTestSharder(t, confer.Sharding{Key: "user_id", Strategy: ShardingRange, Tables: 4, Ranges: []int64{100, 200, 300}})
+	tests := []struct {
+		name    string
+		s       *Sharder
+		key     interface{}
+		want    int
+		wantErr bool
+	}{
+		{"mod int", mod, int64(5), 1, false},
+		{"mod int8", mod, int8(7), 3, false},
+		{"mod pointer", mod, func() *int { n := 6; return &n }(), 2, false},
+		{"mod negative", mod, int64(-5), 1, false},
+		{"mod min int64", mod, int64(math.MinInt64), 0, false},
+		{"mod uint64 above max int64", mod, uint64(math.MaxUint64), 3, false},
+		{"mod uint64 1<<63", mod, uint64(1 << 63), 0, false},
+		{"mod unsupported", mod, 1.5, 0, true},
+		{"mod nil pointer", mod, (*int)(nil), 0, true},
+		{"range first", rng, int64(99), 0, false},
+		{"range boundary", rng, 100, 1, false},
+		{"range negative", rng, -1, 0, false},
+		{"range numeric string", rng, "250", 2, false},
+		{"range last", rng, 300, 3, false},
+		{"range uint64 above max int64", rng, uint64(math.MaxUint64), 3, false},
+		{"range big numeric string", rng, "18446744073709551615", 3, false},
+		{"range not integer", rng, "abc", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.s.index(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("index(%v) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("index(%v) = %d, want %d", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSharderIndexSameKey(t *testing.T) {
+	for _, strategy := range []string{ShardingMod, ShardingHash} {
+		s := newTestSharder(t, confer.Sharding{Key: "code", Strategy: strategy, Tables: 8})
+		pairs := [][2]interface{}{
+			{"abc", []byte("abc")},
+			{"abc", func() *string { v := "abc"; return &v }()},
+			{int32(42), uint16(42)},
+		}
+		if strategy == ShardingHash {
+			// hash按字符串形式计算
+			pairs = append(pairs, [2]interface{}{"42", 42})
+		}
+		for _, p := range pairs {
+			a, errA := s.index(p[0])
+			b, errB := s.index(p[1])
+			if errA != nil || errB != nil || a != b {
+				t.Errorf("%s: index(%#v) = %d, %v; index(%#v) = %d, %v", strategy, p[0], a, errA, p[1], b, errB)
+			}
+		}
+	}
+}
+
+type testOrder struct {
+	ID     int64
+	UserID int64
+	Code   string
+}
+
+func TestConvertKey(t *testing.T) {
+	sch, err := schema.Parse(&testOrder{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, code := sch.LookUpField("user_id"), sch.LookUpField("code")
+	tests := []struct {
+		name  string
+		field *schema.Field
+		value interface{}
+		want  interface{}
+	}{
+		{"numeric string for int column", userID, "5", int64(5)},
+		{"negative string for int column", userID, "-5", int64(-5)},
+		{"int for int column", userID, int64(5), int64(5)},
+		{"big numeric string for uint column", userID, "18446744073709551615", uint64(math.MaxUint64)},
+		{"text for int column", userID, "abc", "abc"},
+		{"int for string column", code, 5, "5"},
+		{"string for string column", code, "5", "5"},
+		{"no field", nil, "5", "5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertKey(tt.field, tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertKey(%v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	// query参数中的分片键与写入时的整数分片键落在同一分片
+	s := newTestSharder(t, confer.Sharding{Key: "user_id", Tables: 4})
+	written, _ := s.Locate(int64(5))
+	queried, _ := s.Locate(convertKey(userID, "5"))
+	if written != queried {
+		t.Errorf("Locate(\"5\") = %+v, want %+v", queried, written)
+	}
+}
+
+func TestExprKeyValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		exprs   []clause.Expression
+		primary string
+		want    []interface{}
+		ok      bool
+	}{
+		{"eq", []clause.Expression{clause.Eq{Column: "user_id", Value: 5}}, "", []interface{}{5}, true},
+		{"eq qualified column", []clause.Expression{clause.Eq{Column: "`orders`.`user_id`", Value: 5}}, "", []interface{}{5}, true},
+		{"eq column struct", []clause.Expression{clause.Eq{Column: clause.Column{Name: "user_id"}, Value: "5"}}, "", []interface{}{"5"}, true},
+		{"in", []clause.Expression{clause.IN{Column: clause.Column{Name: "user_id"}, Values: []interface{}{1, 2}}}, "", []interface{}{1, 2}, true},
+		{"primary key", []clause.Expression{clause.Eq{Column: clause.PrimaryColumn, Value: 7}}, "user_id", []interface{}{7}, true},
+		{"primary key is not shard key", []clause.Expression{clause.Eq{Column: clause.PrimaryColumn, Value: 7}}, "id", nil, false},
+		{"expr eq", []clause.Expression{clause.Expr{SQL: "user_id = ?", Vars: []interface{}{"5"}}}, "", []interface{}{"5"}, true},
+		{"expr in", []clause.Expression{clause.Expr{SQL: "`orders`.`user_id` IN (?)", Vars: []interface{}{[]int64{1, 2}}}}, "", []interface{}{int64(1), int64(2)}, true},
+		{"expr other operator", []clause.Expression{clause.Expr{SQL: "user_id > ?", Vars: []interface{}{5}}}, "", nil, false},
+		{"expr other column", []clause.Expression{clause.Expr{SQL: "status = ?", Vars: []interface{}{1}}}, "", nil, false},
+		{"and", []clause.Expression{
+			clause.Eq{Column: "status", Value: 1},
+			clause.AndConditions{Exprs: []clause.Expression{clause.Eq{Column: "user_id", Value: 3}}},
+		}, "", []interface{}{3}, true},
+		{"or", []clause.Expression{
+			clause.Eq{Column: "user_id", Value: 3},
+			clause.OrConditions{Exprs: []clause.Expression{clause.Eq{Column: "status", Value: 1}}},
+		}, "", nil, false},
+		{"not", []clause.Expression{clause.NotConditions{Exprs: []clause.Expression{clause.Eq{Column: "user_id", Value: 3}}}}, "", nil, false},
+		{"empty", nil, "", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := exprKeyValues(tt.exprs, "user_id", tt.primary)
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("exprKeyValues() = %#v, %v, want %#v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
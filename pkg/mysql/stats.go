@@ -0,0 +1,109 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PoolStats 连接池状态，Waiting及Exhausted为pool-wait-count/pool-wait-timeout限制的等待
+type PoolStats struct {
+	MaxOpen           int           `json:"maxOpen"`
+	OpenConns         int           `json:"openConns"`
+	InUse             int           `json:"inUse"`
+	Idle              int           `json:"idle"`
+	WaitCount         int64         `json:"waitCount"`
+	WaitDuration      time.Duration `json:"waitDuration"`
+	MaxIdleClosed     int64         `json:"maxIdleClosed"`
+	MaxIdleTimeClosed int64         `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed int64         `json:"maxLifetimeClosed"`
+	Waiting           int64         `json:"waiting"`
+	Exhausted         int64         `json:"exhausted"`
+}
+
+// ReplicaState 读库状态
+type ReplicaState struct {
+	Addr    string `json:"addr"`
+	Weight  int    `json:"weight"`
+	Healthy bool   `json:"healthy"`
+	PoolStats
+}
+
+// DatasourceStats 数据源写库连接池及读库的状态
+type DatasourceStats struct {
+	Name string `json:"name"`
+	PoolStats
+	Replicas []ReplicaState `json:"replicas,omitempty"`
+}
+
+// Stats 全部数据源写库连接池及读库的状态，按名称排序
+func Stats() []DatasourceStats {
+	names := Datasources()
+	result := make([]DatasourceStats, 0, len(names))
+	for _, name := range names {
+		ds := lookup(name)
+		stats := DatasourceStats{Name: name, Replicas: ds.replicaStates()}
+		if sqlDB, err := ds.write.DB.DB(); err == nil {
+			stats.PoolStats = poolStats(sqlDB)
+		}
+		result = append(result, stats)
+	}
+	return result
+}
+
+func poolStats(sqlDB *sql.DB) PoolStats {
+	s := sqlDB.Stats()
+	stats := PoolStats{
+		MaxOpen:           s.MaxOpenConnections,
+		OpenConns:         s.OpenConnections,
+		InUse:             s.InUse,
+		Idle:              s.Idle,
+		WaitCount:         s.WaitCount,
+		WaitDuration:      s.WaitDuration,
+		MaxIdleClosed:     s.MaxIdleClosed,
+		MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+		MaxLifetimeClosed: s.MaxLifetimeClosed,
+	}
+	if limit := limitOf(sqlDB); limit != nil {
+		stats.Waiting, stats.Exhausted = limit.waiting.Load(), limit.exhausted.Load()
+	}
+	return stats
+}
+
+// Replicas 默认数据源全部读库的状态
+func Replicas() []ReplicaState {
+	if ds := lookup(DefaultDatasource); ds != nil {
+		return ds.replicaStates()
+	}
+	return nil
+}
+
+func (ds *datasource) replicaStates() []ReplicaState {
+	datasourcesMu.RLock()
+	set := ds.replicas
+	datasourcesMu.RUnlock()
+	if set == nil {
+		return nil
+	}
+	states := make([]ReplicaState, 0, len(set.replicas))
+	for _, r := range set.replicas {
+		states = append(states, ReplicaState{Addr: r.addr, Weight: r.weight, Healthy: r.healthy.Load(),
+			PoolStats: poolStats(r.db)})
+	}
+	return states
+}
+
+// Ping 检查全部数据源的写库，读库由后台健康检查维护，返回第一个失败的数据源
+func Ping(ctx context.Context) error {
+	for _, name := range Datasources() {
+		sqlDB, err := lookup(name).write.DB.DB()
+		if err == nil {
+			err = sqlDB.PingContext(ctx)
+		}
+		if err != nil {
+			return fmt.Errorf("mysql datasource %s: %w", name, err)
+		}
+	}
+	return nil
+}
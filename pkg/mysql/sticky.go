@@ -0,0 +1,55 @@
+package mysql
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type writtenKey struct{}
+
+// written 同一请求内发生过写操作的数据源
+type written struct {
+	sources sync.Map
+}
+
+// WithReadYourWrites 返回带写标记的上下文，使用该上下文(WithContext)写库后，
+// 之后对同一数据源的读操作改为读写库，避免主从延迟读不到刚写入的数据
+func WithReadYourWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(writtenKey{}).(*written); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, writtenKey{}, &written{})
+}
+
+func hasWritten(ctx context.Context, name string) bool {
+	if ctx == nil {
+		return false
+	}
+	w, ok := ctx.Value(writtenKey{}).(*written)
+	if !ok {
+		return false
+	}
+	_, ok = w.sources.Load(name)
+	return ok
+}
+
+func markWritten(db *gorm.DB, name string) {
+	if db.Statement.Context == nil {
+		return
+	}
+	if w, ok := db.Statement.Context.Value(writtenKey{}).(*written); ok {
+		w.sources.Store(name, struct{}{})
+	}
+}
+
+// registerWriteMarker 写库执行写操作后标记上下文
+func registerWriteMarker(db *gorm.DB, name string) {
+	mark := func(db *gorm.DB) { markWritten(db, name) }
+	cb := db.Callback()
+	_ = cb.Create().After("gorm:create").Register("goframe:written", mark)
+	_ = cb.Update().After("gorm:update").Register("goframe:written", mark)
+	_ = cb.Delete().After("gorm:delete").Register("goframe:written", mark)
+	_ = cb.Raw().After("gorm:raw").Register("goframe:written", mark)
+}
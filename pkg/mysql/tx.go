@@ -0,0 +1,248 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTxRetries = 3
+	txRetryBackoff   = 20 * time.Millisecond
+
+	errDeadlock        = 1213
+	errLockWaitTimeout = 1205
+)
+
+type txKey struct{}
+
+// txState 上下文中某个数据源的事务，同数据源的嵌套调用共用该事务并使用保存点，
+// parent为外层其他数据源的事务
+type txState struct {
+	name       string
+	db         *gorm.DB
+	parent     *txState
+	mu         sync.Mutex
+	savepoints int
+	hooks      []func()
+}
+
+type txOptions struct {
+	retries int
+	sql     []*sql.TxOptions
+}
+
+type TxOption func(o *txOptions)
+
+// WithRetries 死锁及锁等待超时时重试整个事务的次数，默认3次，0不重试
+func WithRetries(n int) TxOption {
+	return func(o *txOptions) {
+		o.retries = n
+	}
+}
+
+// WithTxOptions 设置隔离级别及只读
+func WithTxOptions(opts *sql.TxOptions) TxOption {
+	return func(o *txOptions) {
+		o.sql = []*sql.TxOptions{opts}
+	}
+}
+
+// WithTx 在默认数据源的事务中执行fn
+func WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error {
+	return NewDaoMysql().WithTx(ctx, fn, opts...)
+}
+
+// WithTx 在事务中执行fn，fn内通过dao.Orm(ctx)获取事务：
+// 上下文中已有该数据源的事务时加入并使用保存点，fn出错只回滚到保存点；
+// 否则开启新事务，fn返回错误或panic时回滚，死锁时重试整个事务(fn需可重复执行)。
+func (p *DaoMysql) WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error {
+	name := p.datasourceName()
+	if state := currentTx(ctx, name); state != nil {
+		return state.nested(ctx, fn)
+	}
+	o := txOptions{retries: defaultTxRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for attempt := 0; ; attempt++ {
+		err := p.runTx(ctx, name, fn, o.sql)
+		if err == nil || attempt >= o.retries || !IsDeadlock(err) {
+			return err
+		}
+		logger.Infof("mysql %s transaction deadlock, retry %d: %v", name, attempt+1, err)
+		backoff := time.Duration(attempt+1)*txRetryBackoff + time.Duration(rand.Int63n(int64(txRetryBackoff)))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func (p *DaoMysql) runTx(ctx context.Context, name string, fn func(ctx context.Context) error, opts []*sql.TxOptions) (err error) {
+	db := p.GetWriteOrm().DB
+	if db == nil {
+		return fmt.Errorf("mysql datasource %s is not initialized", name)
+	}
+	state := &txState{name: name, parent: txFromContext(ctx)}
+	ctx = context.WithValue(ctx, txKey{}, state)
+	// 事务占用一个连接直到提交或回滚，期间占用连接池名额，获取名额时同样受等待限制
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	release, err := limitOf(sqlDB).acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	tx := db.WithContext(ctx).Begin(opts...)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	state.db = tx
+
+	panicked := true
+	defer func() {
+		if panicked {
+			tx.Rollback()
+		}
+	}()
+	err = fn(ctx)
+	panicked = false
+	if err != nil {
+		if rbErr := tx.Rollback().Error; rbErr != nil {
+			logger.Errorf("mysql %s transaction rollback error: %v", name, rbErr)
+		}
+		return err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return err
+	}
+	state.runHooks()
+	return nil
+}
+
+// nested 同数据源的嵌套事务，使用保存点，回滚时丢弃其中注册的提交回调
+func (s *txState) nested(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	s.mu.Lock()
+	s.savepoints++
+	savepoint := fmt.Sprintf("sp_%d", s.savepoints)
+	hooks := len(s.hooks)
+	s.mu.Unlock()
+	if err = s.db.SavePoint(savepoint).Error; err != nil {
+		return err
+	}
+
+	panicked := true
+	defer func() {
+		if panicked {
+			s.rollbackTo(savepoint, hooks)
+		}
+	}()
+	err = fn(ctx)
+	panicked = false
+	if err != nil {
+		s.rollbackTo(savepoint, hooks)
+	}
+	return err
+}
+
+func (s *txState) rollbackTo(savepoint string, hooks int) {
+	if err := s.db.RollbackTo(savepoint).Error; err != nil {
+		logger.Errorf("mysql %s rollback to savepoint %s error: %v", s.name, savepoint, err)
+	}
+	s.mu.Lock()
+	s.hooks = s.hooks[:hooks]
+	s.mu.Unlock()
+}
+
+// runHooks 提交成功后按注册顺序执行，单个回调panic不影响其他回调
+func (s *txState) runHooks() {
+	for _, hook := range s.hooks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Errorf("mysql %s after commit hook panic: %v", s.name, r)
+				}
+			}()
+			hook()
+		}()
+	}
+}
+
+// OnCommit 注册事务提交成功后执行的回调(如清除缓存、发布事件)，
+// 事务回滚时不执行；上下文中没有事务时立即执行
+func OnCommit(ctx context.Context, fn func()) {
+	state := txFromContext(ctx)
+	if state == nil {
+		fn()
+		return
+	}
+	state.mu.Lock()
+	state.hooks = append(state.hooks, fn)
+	state.mu.Unlock()
+}
+
+// InTx 上下文中是否有事务
+func InTx(ctx context.Context) bool {
+	return txFromContext(ctx) != nil
+}
+
+// Orm 上下文中有该数据源的事务时返回事务，否则返回写库
+func (p *DaoMysql) Orm(ctx context.Context) *gorm.DB {
+	if state := currentTx(ctx, p.datasourceName()); state != nil {
+		return state.db.WithContext(ctx)
+	}
+	return p.GetWriteOrm().WithContext(ctx)
+}
+
+// ReadOrm 上下文中有该数据源的事务时返回事务，否则返回读库
+func (p *DaoMysql) ReadOrm(ctx context.Context) *gorm.DB {
+	if state := currentTx(ctx, p.datasourceName()); state != nil {
+		return state.db.WithContext(ctx)
+	}
+	return p.GetReadOrm().WithContext(ctx)
+}
+
+// IsDeadlock 死锁或锁等待超时
+func IsDeadlock(err error) bool {
+	var myErr *mysqlDriver.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == errDeadlock || myErr.Number == errLockWaitTimeout
+	}
+	return false
+}
+
+func (p *DaoMysql) datasourceName() string {
+	if p.Datasource == "" {
+		return DefaultDatasource
+	}
+	return p.Datasource
+}
+
+func txFromContext(ctx context.Context) *txState {
+	if ctx == nil {
+		return nil
+	}
+	state, _ := ctx.Value(txKey{}).(*txState)
+	return state
+}
+
+func currentTx(ctx context.Context, name string) *txState {
+	for state := txFromContext(ctx); state != nil; state = state.parent {
+		if state.name == name {
+			return state
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestIsDeadlock(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysqlDriver.MySQLError{Number: errDeadlock}, true},
+		{&mysqlDriver.MySQLError{Number: errLockWaitTimeout}, true},
+		{fmt.Errorf("update order: %w", &mysqlDriver.MySQLError{Number: errDeadlock}), true},
+		{&mysqlDriver.MySQLError{Number: 1062}, false},
+		{errors.New("Deadlock found when trying to get lock"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsDeadlock(tt.err); got != tt.want {
+			t.Errorf("IsDeadlock(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// testTx 不连接数据库的事务，记录执行的保存点语句
+func testTx(t *testing.T) (context.Context, *txState, *[]string) {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/test", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statements []string
+	err = db.Callback().Raw().After("gorm:raw").Register("test:record", func(db *gorm.DB) {
+		statements = append(statements, db.Statement.SQL.String())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &txState{name: DefaultDatasource, db: db}
+	return context.WithValue(context.Background(), txKey{}, state), state, &statements
+}
+
+func TestNestedTxHooks(t *testing.T) {
+	ctx, state, statements := testTx(t)
+	var ran []string
+	hook := func(name string) func() {
+		return func() { ran = append(ran, name) }
+	}
+	errFailed := errors.New("failed")
+
+	OnCommit(ctx, hook("outer"))
+	// 回滚到保存点时丢弃其中注册的回调
+	if err := state.nested(ctx, func(ctx context.Context) error {
+		OnCommit(ctx, hook("rolled back"))
+		return errFailed
+	}); err != errFailed {
+		t.Fatalf("nested = %v, want %v", err, errFailed)
+	}
+	// 成功的嵌套保留回调，其中失败的内层只丢弃内层的回调
+	if err := state.nested(ctx, func(ctx context.Context) error {
+		OnCommit(ctx, hook("nested"))
+		_ = state.nested(ctx, func(ctx context.Context) error {
+			OnCommit(ctx, hook("inner rolled back"))
+			return errFailed
+		})
+		OnCommit(ctx, func() { panic("hook panic") })
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	func() {
+		defer func() { _ = recover() }()
+		_ = state.nested(ctx, func(ctx context.Context) error {
+			OnCommit(ctx, hook("panicked"))
+			panic("fn panic")
+		})
+	}()
+	OnCommit(ctx, hook("last"))
+
+	state.runHooks()
+	if want := []string{"outer", "nested", "last"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("hooks ran %v, want %v", ran, want)
+	}
+	want := []string{
+		"SAVEPOINT sp_1", "ROLLBACK TO SAVEPOINT sp_1",
+		"SAVEPOINT sp_2", "SAVEPOINT sp_3", "ROLLBACK TO SAVEPOINT sp_3",
+		"SAVEPOINT sp_4", "ROLLBACK TO SAVEPOINT sp_4",
+	}
+	if !reflect.DeepEqual(*statements, want) {
+		t.Errorf("statements = %v, want %v", *statements, want)
+	}
+}
+
+func TestOnCommitWithoutTx(t *testing.T) {
+	ran := false
+	OnCommit(context.Background(), func() { ran = true })
+	if !ran {
+		t.Error("OnCommit without transaction: hook not run immediately")
+	}
+	if InTx(context.Background()) {
+		t.Error("InTx(context.Background()) = true")
+	}
+	// 其他数据源的事务中不加入
+	ctx, state, _ := testTx(t)
+	if currentTx(ctx, DefaultDatasource) != state || currentTx(ctx, "other") != nil {
+		t.Error("currentTx returns the transaction of another datasource")
+	}
+}
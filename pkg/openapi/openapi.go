@@ -0,0 +1,275 @@
+package openapi
+
+import (
+	"fmt"
+	"goframe/pkg/confer"
+	"goframe/pkg/handler"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SpecPath = "/openapi.json"
+
+	errorSchemaName = "ErrorResponse"
+)
+
+// 不出现在文档中的路由
+var skipPrefixes = []string{SpecPath, "/swagger/", "/debug/pprof"}
+
+// Build 根据gin已注册的路由生成OpenAPI 3文档
+// 通过handler包注册的类型化路由包含请求参数与响应data的schema，其余路由只包含路径参数及通用响应。
+func Build(routes gin.RoutesInfo) *Document {
+	typed := make(map[string]handler.Route)
+	for _, r := range handler.Routes() {
+		typed[r.Method+" "+r.Path] = r
+	}
+	gen := newSchemaGenerator()
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       confer.ConfigAppGetString("sysname", "goframe"),
+			Description: "响应统一为 {code, message, data} 结构，code说明见 " + errorSchemaName + "。",
+			Version:     confer.GetGlobalConfig().Log.App.AppVersion,
+		},
+		Paths: make(map[string]*PathItem),
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "1.0.0"
+	}
+	for _, ri := range routes {
+		if skipRoute(ri.Path) {
+			continue
+		}
+		var op *Operation
+		if r, ok := typed[ri.Method+" "+ri.Path]; ok {
+			op = typedOperation(gen, r)
+		} else {
+			op = plainOperation(gen, ri)
+		}
+		op.OperationID = operationID(ri.Method, ri.Path)
+		op.Responses["default"] = &Response{
+			Description: "业务错误",
+			Content:     jsonContent(&Schema{Ref: "#/components/schemas/" + errorSchemaName}),
+		}
+		setOperation(doc, ri.Method, openapiPath(ri.Path), op)
+	}
+	gen.schemas[errorSchemaName] = errorSchema()
+	doc.Components.Schemas = gen.schemas
+	return doc
+}
+
+// Handler 输出OpenAPI文档，首次请求时生成，此时路由已全部注册
+func Handler(engine *gin.Engine) gin.HandlerFunc {
+	var (
+		once sync.Once
+		doc  *Document
+	)
+	return func(c *gin.Context) {
+		once.Do(func() {
+			doc = Build(engine.Routes())
+		})
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+func skipRoute(path string) bool {
+	for _, prefix := range skipPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func typedOperation(gen *schemaGenerator, r handler.Route) *Operation {
+	op := &Operation{
+		Tags:        r.Tags,
+		Summary:     r.Summary,
+		Description: r.Description,
+		Responses:   make(map[string]*Response),
+	}
+	reqType := r.Request
+	for reqType != nil && reqType.Kind() == reflect.Ptr {
+		reqType = reqType.Elem()
+	}
+	hasBody := r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodDelete
+	if reqType != nil && reqType.Kind() == reflect.Struct {
+		op.Parameters = requestParameters(gen, reqType, r.Path, hasBody)
+		if hasBody {
+			body := gen.structSchema(reqType, isBodyField)
+			if len(body.Properties) > 0 {
+				op.RequestBody = &RequestBody{Required: len(body.Required) > 0, Content: jsonContent(body)}
+			}
+		}
+	}
+	op.Responses["200"] = &Response{Description: "成功", Content: jsonContent(envelope(gen.schemaOf(r.Response)))}
+	return op
+}
+
+// plainOperation 未通过handler注册的路由，只有路径参数，摘要为空(不输出Go函数名)
+func plainOperation(gen *schemaGenerator, ri gin.RouteInfo) *Operation {
+	op := &Operation{
+		Responses: make(map[string]*Response),
+	}
+	for _, name := range pathParams(ri.Path) {
+		op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	op.Responses["200"] = &Response{Description: "成功", Content: jsonContent(envelope(&Schema{}))}
+	return op
+}
+
+// requestParameters uri tag为路径参数；form tag为query参数，有请求体时只包含未声明json tag的字段
+func requestParameters(gen *schemaGenerator, t reflect.Type, path string, hasBody bool) (params []Parameter) {
+	declared := make(map[string]bool)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				walk(field.Type)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			if name := tagName(field, "uri"); name != "" {
+				declared[name] = true
+				params = append(params, Parameter{Name: name, In: "path", Required: true,
+					Description: field.Tag.Get("description"), Schema: gen.schemaOf(field.Type)})
+				continue
+			}
+			name := tagName(field, "form")
+			if name == "" || (hasBody && tagName(field, "json") != "") {
+				continue
+			}
+			params = append(params, Parameter{Name: name, In: "query", Required: isRequired(field),
+				Description: field.Tag.Get("description"), Schema: gen.schemaOf(field.Type)})
+		}
+	}
+	walk(t)
+	// 请求结构体未声明的路径参数
+	for _, name := range pathParams(path) {
+		if !declared[name] {
+			params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	return
+}
+
+// isBodyField 请求体只包含非uri字段，且有form tag的字段需同时声明json tag
+func isBodyField(field reflect.StructField) bool {
+	if tagName(field, "uri") != "" {
+		return false
+	}
+	if tagName(field, "form") != "" && tagName(field, "json") == "" {
+		return false
+	}
+	return true
+}
+
+func tagName(field reflect.StructField, tag string) string {
+	name := strings.Split(field.Tag.Get(tag), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// envelope 统一响应结构
+func envelope(data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer", Description: "业务code，成功为1001"},
+			"message": {Type: "string"},
+			"data":    data,
+		},
+		Required: []string{"code", "message"},
+	}
+}
+
+// errorSchema 错误响应，code取值及说明来自配置文件的code列表
+func errorSchema() *Schema {
+	codes := make([]int, 0)
+	for k := range confer.GetGlobalConfig().Code {
+		if code, err := strconv.Atoi(k); err == nil {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	enum := make([]interface{}, 0, len(codes))
+	lines := make([]string, 0, len(codes))
+	for _, code := range codes {
+		enum = append(enum, code)
+		lines = append(lines, fmt.Sprintf("%d: %v", code, confer.GetGlobalConfig().Code[strconv.Itoa(code)]))
+	}
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer", Enum: enum, Description: strings.Join(lines, "\n")},
+			"message": {Type: "string"},
+			"data":    {Nullable: true},
+		},
+		Required: []string{"code", "message"},
+	}
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+// openapiPath gin路径转换为OpenAPI格式，/users/:id/*path => /users/{id}/{path}
+func openapiPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func pathParams(path string) (names []string) {
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			names = append(names, seg[1:])
+		}
+	}
+	return
+}
+
+func operationID(method, path string) string {
+	id := strings.ToLower(method) + invalidName.ReplaceAllString(openapiPath(path), "_")
+	return strings.TrimRight(id, "_")
+}
+
+func setOperation(doc *Document, method, path string, op *Operation) {
+	item, ok := doc.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		doc.Paths[path] = item
+	}
+	switch method {
+	case http.MethodGet:
+		item.Get = op
+	case http.MethodPost:
+		item.Post = op
+	case http.MethodPut:
+		item.Put = op
+	case http.MethodPatch:
+		item.Patch = op
+	case http.MethodDelete:
+		item.Delete = op
+	case http.MethodHead:
+		item.Head = op
+	case http.MethodOptions:
+		item.Options = op
+	}
+}
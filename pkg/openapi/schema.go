@@ -0,0 +1,153 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	invalidName    = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+// schemaGenerator 通过反射生成schema，结构体统一放入components并以$ref引用
+type schemaGenerator struct {
+	schemas map[string]*Schema
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{schemas: make(map[string]*Schema)}
+}
+
+func (g *schemaGenerator) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+	var s *Schema
+	switch {
+	case t == timeType:
+		s = &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		s = &Schema{}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		s = &Schema{Type: "string", Format: "byte"}
+	default:
+		switch t.Kind() {
+		case reflect.Bool:
+			s = &Schema{Type: "boolean"}
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+			s = &Schema{Type: "integer", Format: "int32"}
+		case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+			s = &Schema{Type: "integer", Format: "int64"}
+		case reflect.Float32:
+			s = &Schema{Type: "number", Format: "float"}
+		case reflect.Float64:
+			s = &Schema{Type: "number", Format: "double"}
+		case reflect.String:
+			s = &Schema{Type: "string"}
+		case reflect.Slice, reflect.Array:
+			s = &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+		case reflect.Map:
+			s = &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+		case reflect.Struct:
+			return g.structRef(t)
+		default:
+			s = &Schema{}
+		}
+	}
+	s.Nullable = nullable
+	return s
+}
+
+// structRef 结构体schema放入components，已存在时直接引用，支持递归类型
+func (g *schemaGenerator) structRef(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return g.structSchema(t, nil)
+	}
+	name := schemaName(t)
+	if _, ok := g.schemas[name]; !ok {
+		placeholder := &Schema{}
+		g.schemas[name] = placeholder
+		*placeholder = *g.structSchema(t, nil)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// structSchema 以json tag生成对象schema，filter返回false的字段被跳过
+func (g *schemaGenerator) structSchema(t reflect.Type, filter func(reflect.StructField) bool) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.collectFields(s, t, filter)
+	return s
+}
+
+func (g *schemaGenerator) collectFields(s *Schema, t reflect.Type, filter func(reflect.StructField) bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := jsonName(field)
+		if field.Anonymous && !strings.Contains(string(field.Tag), "json:") {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.collectFields(s, ft, filter)
+				continue
+			}
+		}
+		if !ok || !field.IsExported() || (filter != nil && !filter(field)) {
+			continue
+		}
+		prop := g.schemaOf(field.Type)
+		// $ref 不允许存在兄弟属性，引用类型的字段不输出说明
+		if desc := field.Tag.Get("description"); desc != "" && prop.Ref == "" {
+			prop.Description = desc
+		}
+		s.Properties[name] = prop
+		if isRequired(field) {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// jsonName 字段在json中的名称，json:"-"时返回false
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, true
+	}
+	return field.Name, true
+}
+
+// isRequired binding或validate tag中包含required
+func isRequired(field reflect.StructField) bool {
+	for _, tag := range []string{"binding", "validate"} {
+		for _, rule := range strings.Split(field.Tag.Get(tag), ",") {
+			if rule == "required" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// schemaName 包名.类型名，泛型实例化类型中的非法字符替换为下划线
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if pkg := t.PkgPath(); pkg != "" {
+		name = path.Base(pkg) + "." + name
+	}
+	return strings.Trim(invalidName.ReplaceAllString(name, "_"), "_")
+}
@@ -0,0 +1,180 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"goframe/pkg/response"
+	"goframe/pkg/validate"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cursorTimeLayout 游标中时间类型的格式，mysql可直接与datetime比较
+const cursorTimeLayout = "2006-01-02 15:04:05.999999"
+
+// Where 追加过滤条件，列名来自Spec白名单，值全部参数化
+func Where(db *gorm.DB, p *Params) *gorm.DB {
+	for _, cond := range p.Conditions {
+		db = db.Where(conditionExpr(cond))
+	}
+	return db
+}
+
+// Order 追加排序
+func Order(db *gorm.DB, p *Params) *gorm.DB {
+	for _, s := range p.Sorts {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: s.Column}, Desc: s.Desc})
+	}
+	return db
+}
+
+// Apply 追加过滤、排序及分页，偏移分页使用Offset/Limit，游标分页多查询一条用于判断是否还有数据
+func Apply(db *gorm.DB, p *Params) (*gorm.DB, error) {
+	db = Order(Where(db, p), p)
+	if !p.Keyset {
+		return db.Offset((p.Page - 1) * p.Size).Limit(p.Size), nil
+	}
+	if p.Cursor != "" {
+		values, err := decodeCursor(p.Cursor, len(p.Sorts))
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(keysetExpr(p.Sorts, values))
+	}
+	return db.Limit(p.Size + 1), nil
+}
+
+// Paginate 查询一页数据，db需已指定Model或Table，如
+//
+//	page, err := query.Paginate[User](dao.GetReadOrm().DB.Model(&User{}), params)
+//
+// 偏移分页会额外执行一次count，游标分页不统计总数
+func Paginate[T any](db *gorm.DB, p *Params) (*response.Page[T], error) {
+	page := &response.Page[T]{Items: make([]T, 0), Size: p.Size}
+	if !p.Keyset {
+		var total int64
+		if err := Where(db.Session(&gorm.Session{}), p).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = &total
+		page.Page = p.Page
+		page.HasMore = int64(p.Page*p.Size) < total
+		if int64((p.Page-1)*p.Size) >= total {
+			return page, nil
+		}
+	}
+	q, err := Apply(db, p)
+	if err != nil {
+		return nil, err
+	}
+	if err = q.Find(&page.Items).Error; err != nil {
+		return nil, err
+	}
+	if p.Keyset && len(page.Items) > p.Size {
+		page.Items = page.Items[:p.Size]
+		page.HasMore = true
+		page.NextCursor, err = encodeCursor(db, p.Sorts, &page.Items[len(page.Items)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func conditionExpr(cond Condition) clause.Expression {
+	column := clause.Column{Name: cond.Column}
+	switch cond.Op {
+	case OpNe:
+		return clause.Neq{Column: column, Value: cond.Value}
+	case OpGt:
+		return clause.Gt{Column: column, Value: cond.Value}
+	case OpGte:
+		return clause.Gte{Column: column, Value: cond.Value}
+	case OpLt:
+		return clause.Lt{Column: column, Value: cond.Value}
+	case OpLte:
+		return clause.Lte{Column: column, Value: cond.Value}
+	case OpLike:
+		return clause.Like{Column: column, Value: cond.Value}
+	case OpIn:
+		values := make([]interface{}, 0)
+		for _, v := range cond.Value.([]string) {
+			values = append(values, v)
+		}
+		return clause.IN{Column: column, Values: values}
+	default:
+		return clause.Eq{Column: column, Value: cond.Value}
+	}
+}
+
+// keysetExpr 排序列(a, b, id)的游标条件：
+// a > ? OR (a = ? AND b > ?) OR (a = ? AND b = ? AND id > ?)，降序的列使用 <
+func keysetExpr(sorts []Sort, values []interface{}) clause.Expression {
+	ors := make([]clause.Expression, 0, len(sorts))
+	for i, s := range sorts {
+		ands := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, clause.Eq{Column: clause.Column{Name: sorts[j].Column}, Value: values[j]})
+		}
+		column := clause.Column{Name: s.Column}
+		if s.Desc {
+			ands = append(ands, clause.Lt{Column: column, Value: values[i]})
+		} else {
+			ands = append(ands, clause.Gt{Column: column, Value: values[i]})
+		}
+		ors = append(ors, clause.And(ands...))
+	}
+	return clause.Or(ors...)
+}
+
+// encodeCursor 取最后一条记录的排序列值，base64(json数组)
+func encodeCursor(db *gorm.DB, sorts []Sort, last interface{}) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(last); err != nil {
+		return "", err
+	}
+	rv := reflect.Indirect(reflect.ValueOf(last))
+	values := make([]interface{}, 0, len(sorts))
+	for _, s := range sorts {
+		field := stmt.Schema.LookUpField(s.Column)
+		if field == nil {
+			return "", gorm.ErrInvalidField
+		}
+		v, _ := field.ValueOf(context.Background(), rv)
+		if t, ok := v.(time.Time); ok {
+			v = t.Format(cursorTimeLayout)
+		}
+		values = append(values, v)
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string, n int) ([]interface{}, error) {
+	invalid := validate.Errors{{Field: ParamCursor, Rule: "cursor", Message: "invalid cursor"}}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	values := make([]interface{}, 0)
+	if err = decoder.Decode(&values); err != nil || len(values) != n {
+		// 排序参数与生成游标时不一致
+		return nil, invalid
+	}
+	for i, v := range values {
+		if num, ok := v.(json.Number); ok {
+			values[i] = num.String()
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,103 @@
+package query
+
+import (
+	"errors"
+	"goframe/pkg/validate"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type testItem struct {
+	ID        uint64
+	Name      string
+	Score     float64
+	CreatedAt time.Time
+}
+
+// dryRunDB 只生成sql不连接数据库
+func dryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/test", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestKeysetExpr(t *testing.T) {
+	tests := []struct {
+		sorts    []Sort
+		values   []interface{}
+		wantSQL  string
+		wantVars []interface{}
+	}{
+		{
+			[]Sort{{Column: "id"}},
+			[]interface{}{"10"},
+			"SELECT * FROM `test_item` WHERE `id` > ?",
+			[]interface{}{"10"},
+		},
+		{
+			[]Sort{{Column: "id", Desc: true}},
+			[]interface{}{"10"},
+			"SELECT * FROM `test_item` WHERE `id` < ?",
+			[]interface{}{"10"},
+		},
+		{
+			[]Sort{{Column: "created_at", Desc: true}, {Column: "name"}, {Column: "id"}},
+			[]interface{}{"2026-10-19 12:00:00", "a", "10"},
+			"SELECT * FROM `test_item` WHERE (`created_at` < ? OR (`created_at` = ? AND `name` > ?) OR " +
+				"(`created_at` = ? AND `name` = ? AND `id` > ?))",
+			[]interface{}{"2026-10-19 12:00:00", "2026-10-19 12:00:00", "a", "2026-10-19 12:00:00", "a", "10"},
+		},
+	}
+	db := dryRunDB(t)
+	for _, tt := range tests {
+		stmt := db.Table("test_item").Where(keysetExpr(tt.sorts, tt.values)).Find(&[]testItem{}).Statement
+		if got := stmt.SQL.String(); got != tt.wantSQL {
+			t.Errorf("keysetExpr(%v) sql = %s, want %s", tt.sorts, got, tt.wantSQL)
+		}
+		if !reflect.DeepEqual(stmt.Vars, tt.wantVars) {
+			t.Errorf("keysetExpr(%v) vars = %v, want %v", tt.sorts, stmt.Vars, tt.wantVars)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	db := dryRunDB(t)
+	sorts := []Sort{{Column: "created_at", Desc: true}, {Column: "score"}, {Column: "name"}, {Column: "id"}}
+	last := &testItem{ID: 18446744073709551615, Name: "a\"b", Score: 1.5,
+		CreatedAt: time.Date(2026, 10, 19, 12, 0, 0, 123000000, time.UTC)}
+	cursor, err := encodeCursor(db, sorts, last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := decodeCursor(cursor, len(sorts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 数字保持原始精度，时间为mysql可比较的格式
+	want := []interface{}{"2026-10-19 12:00:00.123", "1.5", "a\"b", "18446744073709551615"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("decodeCursor = %v, want %v", values, want)
+	}
+
+	if _, err = encodeCursor(db, []Sort{{Column: "unknown"}}, last); err == nil {
+		t.Error("encodeCursor with unknown column: want error")
+	}
+	for _, invalid := range []string{cursor + "!", "bm90IGpzb24", ""} {
+		_, err = decodeCursor(invalid, len(sorts))
+		var errs validate.Errors
+		if !errors.As(err, &errs) || errs[0].Field != ParamCursor {
+			t.Errorf("decodeCursor(%q) error = %v, want invalid cursor", invalid, err)
+		}
+	}
+	// 排序参数与生成游标时不一致
+	if _, err = decodeCursor(cursor, len(sorts)-1); err == nil {
+		t.Error("decodeCursor with different sort count: want error")
+	}
+}
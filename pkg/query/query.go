@@ -0,0 +1,203 @@
+package query
+
+import (
+	"fmt"
+	"goframe/pkg/validate"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	OpEq   = "eq"
+	OpNe   = "ne"
+	OpGt   = "gt"
+	OpGte  = "gte"
+	OpLt   = "lt"
+	OpLte  = "lte"
+	OpLike = "like"
+	OpIn   = "in"
+
+	defaultSize = 20
+	maxSize     = 100
+)
+
+// 分页、排序使用的保留参数名
+const (
+	ParamPage   = "page"
+	ParamSize   = "size"
+	ParamCursor = "cursor"
+	ParamSort   = "sort"
+)
+
+// Filter 允许过滤的字段，Ops为空时只允许eq
+type Filter struct {
+	Column string
+	Ops    []string
+}
+
+// Spec 列表接口允许的分页、排序、过滤参数，只有白名单内的字段会进入sql
+type Spec struct {
+	DefaultSize int               // 默认每页条数，默认20
+	MaxSize     int               // 最大每页条数，默认100
+	Sorts       map[string]string // 排序参数名 => 列名
+	DefaultSort string            // 默认排序，如 "-id"
+	Filters     map[string]Filter // 过滤参数名 => 列及操作
+	KeyColumn   string            // 唯一列，作为排序的最后一项保证分页稳定，默认id
+}
+
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+type Condition struct {
+	Column string
+	Op     string
+	Value  interface{}
+}
+
+// Params 解析后的列表参数
+// 请求带有cursor参数(首页为空值)时使用游标分页，否则使用page/size偏移分页
+type Params struct {
+	Page       int
+	Size       int
+	Cursor     string
+	Keyset     bool
+	Sorts      []Sort
+	Conditions []Condition
+}
+
+// Parse 解析query中的分页、排序及过滤参数
+//
+//	?page=2&size=20&sort=-created_at,name&status=1&score[gte]=60&id[in]=1,2,3
+//	?cursor=&size=50&sort=-id
+//
+// 非法参数返回validate.Errors，typed handler中会转换为CODE_COMMON_PARAMS_INCOMPLETE
+func Parse(c *gin.Context, spec Spec) (*Params, error) {
+	return ParseValues(c.Request.URL.Query(), spec)
+}
+
+func ParseValues(values url.Values, spec Spec) (*Params, error) {
+	spec = withDefaults(spec)
+	p := &Params{Page: 1, Size: spec.DefaultSize}
+	var errs validate.Errors
+
+	if v := values.Get(ParamPage); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			errs = append(errs, validate.FieldError{Field: ParamPage, Rule: "min", Message: "page must be a positive integer"})
+		} else {
+			p.Page = page
+		}
+	}
+	if v := values.Get(ParamSize); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 || size > spec.MaxSize {
+			errs = append(errs, validate.FieldError{Field: ParamSize, Rule: "max",
+				Message: fmt.Sprintf("size must be between 1 and %d", spec.MaxSize)})
+		} else {
+			p.Size = size
+		}
+	}
+	if _, ok := values[ParamCursor]; ok {
+		p.Keyset = true
+		p.Cursor = values.Get(ParamCursor)
+	}
+
+	sortExpr := values.Get(ParamSort)
+	if sortExpr == "" {
+		sortExpr = spec.DefaultSort
+	}
+	hasKey := false
+	for _, item := range strings.Split(sortExpr, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		desc := strings.HasPrefix(item, "-")
+		name := strings.TrimLeft(item, "+-")
+		column, ok := spec.Sorts[name]
+		if !ok && name == spec.KeyColumn {
+			column, ok = spec.KeyColumn, true
+		}
+		if !ok {
+			errs = append(errs, validate.FieldError{Field: ParamSort, Rule: "oneof", Message: "unsupported sort field: " + name})
+			continue
+		}
+		hasKey = hasKey || column == spec.KeyColumn
+		p.Sorts = append(p.Sorts, Sort{Column: column, Desc: desc})
+	}
+	if !hasKey {
+		desc := len(p.Sorts) > 0 && p.Sorts[len(p.Sorts)-1].Desc
+		p.Sorts = append(p.Sorts, Sort{Column: spec.KeyColumn, Desc: desc})
+	}
+
+	for key, vals := range values {
+		name, op := splitFilterKey(key)
+		if name == ParamPage || name == ParamSize || name == ParamCursor || name == ParamSort {
+			continue
+		}
+		filter, ok := spec.Filters[name]
+		if !ok {
+			continue
+		}
+		if !allowOp(filter, op) {
+			errs = append(errs, validate.FieldError{Field: key, Rule: "oneof", Message: "unsupported filter operator: " + op})
+			continue
+		}
+		p.Conditions = append(p.Conditions, Condition{Column: filter.Column, Op: op, Value: filterValue(op, vals[0])})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return p, nil
+}
+
+func withDefaults(spec Spec) Spec {
+	if spec.DefaultSize <= 0 {
+		spec.DefaultSize = defaultSize
+	}
+	if spec.MaxSize <= 0 {
+		spec.MaxSize = maxSize
+	}
+	if spec.KeyColumn == "" {
+		spec.KeyColumn = "id"
+	}
+	return spec
+}
+
+// splitFilterKey status => (status, eq)；score[gte] => (score, gte)
+func splitFilterKey(key string) (string, string) {
+	if i := strings.Index(key, "["); i > 0 && strings.HasSuffix(key, "]") {
+		return key[:i], strings.ToLower(key[i+1 : len(key)-1])
+	}
+	return key, OpEq
+}
+
+func allowOp(filter Filter, op string) bool {
+	if len(filter.Ops) == 0 {
+		return op == OpEq
+	}
+	for _, v := range filter.Ops {
+		if v == op {
+			return true
+		}
+	}
+	return false
+}
+
+func filterValue(op string, value string) interface{} {
+	switch op {
+	case OpIn:
+		return strings.Split(value, ",")
+	case OpLike:
+		// 转义通配符，只做包含匹配
+		replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+		return "%" + replacer.Replace(value) + "%"
+	default:
+		return value
+	}
+}
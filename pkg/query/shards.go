@@ -0,0 +1,290 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"goframe/pkg/response"
+	"goframe/pkg/validate"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// 多个分片偏移分页时每个分片查询前page*size条，超过该数量返回错误，深分页应改用游标分页
+const maxShardWindow = 10000
+
+// ErrShardWindowTooLarge 跨分片偏移分页的页数过大
+var ErrShardWindowTooLarge = errors.New("query: page too deep across shards, use cursor pagination")
+
+// PaginateShards 在多个分片(同一结构的表)上分页：每个分片分别查询后按排序参数合并，
+// 偏移分页的total为各分片之和，游标分页与单表相同。
+// 字符串列的排序由mysql的collation决定(如不区分大小写)，合并时无法一致，只允许按数字、时间列及主键排序
+func PaginateShards[T any](dbs []*gorm.DB, p *Params) (*response.Page[T], error) {
+	if len(dbs) == 1 {
+		return Paginate[T](dbs[0], p)
+	}
+	if err := checkShardSorts[T](dbs[0], p.Sorts); err != nil {
+		return nil, err
+	}
+	page := &response.Page[T]{Items: make([]T, 0), Size: p.Size}
+	limit := p.Size + 1
+	if !p.Keyset {
+		var total int64
+		for _, db := range dbs {
+			var count int64
+			if err := Where(db.Session(&gorm.Session{}), p).Count(&count).Error; err != nil {
+				return nil, err
+			}
+			total += count
+		}
+		page.Total = &total
+		page.Page = p.Page
+		page.HasMore = int64(p.Page*p.Size) < total
+		if int64((p.Page-1)*p.Size) >= total {
+			return page, nil
+		}
+		limit = p.Page * p.Size
+		if limit > maxShardWindow {
+			return nil, ErrShardWindowTooLarge
+		}
+	}
+
+	for _, db := range dbs {
+		q := Order(Where(db, p), p)
+		if p.Keyset && p.Cursor != "" {
+			values, err := decodeCursor(p.Cursor, len(p.Sorts))
+			if err != nil {
+				return nil, err
+			}
+			q = q.Where(keysetExpr(p.Sorts, values))
+		}
+		items := make([]T, 0)
+		if err := q.Limit(limit).Find(&items).Error; err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, items...)
+	}
+	if err := SortItems(dbs[0], page.Items, p.Sorts); err != nil {
+		return nil, err
+	}
+
+	if !p.Keyset {
+		start := (p.Page - 1) * p.Size
+		if start > len(page.Items) {
+			start = len(page.Items)
+		}
+		end := start + p.Size
+		if end > len(page.Items) {
+			end = len(page.Items)
+		}
+		page.Items = page.Items[start:end]
+		return page, nil
+	}
+	if len(page.Items) > p.Size {
+		page.Items = page.Items[:p.Size]
+		page.HasMore = true
+		cursor, err := encodeCursor(dbs[0], p.Sorts, &page.Items[len(page.Items)-1])
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = cursor
+	}
+	return page, nil
+}
+
+// checkShardSorts 跨分片合并只支持数字、时间、布尔列及主键的排序，其他列返回sort参数错误
+func checkShardSorts[T any](db *gorm.DB, sorts []Sort) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return err
+	}
+	for _, s := range sorts {
+		field := stmt.Schema.LookUpField(s.Column)
+		if field == nil {
+			return gorm.ErrInvalidField
+		}
+		switch field.DataType {
+		case schema.Int, schema.Uint, schema.Float, schema.Time, schema.Bool:
+			continue
+		}
+		if !field.PrimaryKey {
+			return validate.Errors{{Field: ParamSort, Rule: "sort",
+				Message: fmt.Sprintf("sorting by %s is not supported across shards", s.Column)}}
+		}
+	}
+	return nil
+}
+
+// SortItems 按排序参数对查询结果排序，用于合并多个分片的结果
+func SortItems[T any](db *gorm.DB, items []T, sorts []Sort) error {
+	if len(sorts) == 0 || len(items) < 2 {
+		return nil
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	keys := make([][]interface{}, len(items))
+	for i := range items {
+		rv := reflect.ValueOf(&items[i]).Elem()
+		keys[i] = make([]interface{}, len(sorts))
+		for j, s := range sorts {
+			field := stmt.Schema.LookUpField(s.Column)
+			if field == nil {
+				return gorm.ErrInvalidField
+			}
+			keys[i][j], _ = field.ValueOf(ctx, rv)
+		}
+	}
+	index := make([]int, len(items))
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(a, b int) bool {
+		for j, s := range sorts {
+			c := Compare(keys[index[a]][j], keys[index[b]][j])
+			if c == 0 {
+				continue
+			}
+			if s.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	sorted := make([]T, len(items))
+	for i, k := range index {
+		sorted[i] = items[k]
+	}
+	copy(items, sorted)
+	return nil
+}
+
+// Compare 比较两个列值：NULL最小，数字、时间按值比较，与mysql排序一致；
+// 字符串按字节比较，只与二进制collation(如utf8mb4_bin)一致
+func Compare(a, b interface{}) int {
+	a, b = compareValue(a), compareValue(b)
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch x := a.(type) {
+	case int64:
+		if y, ok := b.(int64); ok {
+			return compareOrdered(x, y)
+		}
+		if y, ok := b.(uint64); ok {
+			if x < 0 {
+				return -1
+			}
+			return compareOrdered(uint64(x), y)
+		}
+		if y, ok := b.(float64); ok {
+			return compareOrdered(float64(x), y)
+		}
+	case uint64:
+		if y, ok := b.(uint64); ok {
+			return compareOrdered(x, y)
+		}
+		if y, ok := b.(int64); ok {
+			return -Compare(y, x)
+		}
+		if y, ok := b.(float64); ok {
+			return compareOrdered(float64(x), y)
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			return compareOrdered(x, y)
+		}
+		if _, ok := b.(int64); ok {
+			return -Compare(b, a)
+		}
+		if _, ok := b.(uint64); ok {
+			return -Compare(b, a)
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y)
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			switch {
+			case x.Before(y):
+				return -1
+			case x.After(y):
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if y, ok := b.(bool); ok && x != y {
+			if x {
+				return 1
+			}
+			return -1
+		}
+		return 0
+	}
+	return 0
+}
+
+// compareValue 解引用并统一为int64、uint64、float64等基础类型
+func compareValue(v interface{}) interface{} {
+	if valuer, ok := v.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil
+		}
+		v, _ = valuer.Value()
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	}
+	return rv.Interface()
+}
+
+func compareOrdered[V int64 | uint64 | float64](x, y V) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package query
+
+import (
+	"database/sql"
+	"errors"
+	"goframe/pkg/validate"
+	"math"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestCompare(t *testing.T) {
+	now := time.Now()
+	one := int64(1)
+	var nilInt *int64
+	tests := []struct {
+		a, b interface{}
+		want int
+	}{
+		{1, 2, -1},
+		{int8(3), int64(3), 0},
+		{uint64(math.MaxUint64), int64(math.MaxInt64), 1},
+		{int64(-1), uint64(0), -1},
+		{uint64(0), int64(-1), 1},
+		{uint32(2), 1.5, 1},
+		{1.5, int32(2), -1},
+		{float32(2.5), 2.5, 0},
+		{"a", "b", -1},
+		// 按字节比较，与不区分大小写的collation不同
+		{"B", "a", -1},
+		{[]byte("b"), []byte("a"), 1},
+		{now, now.Add(time.Second), -1},
+		{now, now, 0},
+		{true, false, 1},
+		{&one, 1, 0},
+		{nil, 1, -1},
+		{nilInt, nil, 0},
+		{"a", nil, 1},
+		{sql.NullInt64{Int64: 5, Valid: true}, 4, 1},
+		{sql.NullInt64{}, 4, -1},
+		{gorm.DeletedAt{}, gorm.DeletedAt{Time: now, Valid: true}, -1},
+		{(*gorm.DeletedAt)(nil), 0, -1},
+	}
+	for _, tt := range tests {
+		if got := Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPaginateShardsSort(t *testing.T) {
+	db := dryRunDB(t)
+	dbs := []*gorm.DB{db.Table("test_item_0"), db.Table("test_item_1")}
+	// 字符串列在mysql中按collation排序(如utf8mb4_general_ci中 "a" < "B")，
+	// 与合并时的顺序不一致会跳过记录，跨分片时不允许
+	_, err := PaginateShards[testItem](dbs, &Params{Size: 1, Keyset: true, Sorts: []Sort{{Column: "name"}, {Column: "id"}}})
+	var errs validate.Errors
+	if !errors.As(err, &errs) || errs[0].Field != ParamSort {
+		t.Errorf("PaginateShards sorted by name: error = %v, want sort error", err)
+	}
+	for _, sorts := range [][]Sort{
+		{{Column: "id"}},
+		{{Column: "created_at", Desc: true}, {Column: "id"}},
+		{{Column: "score"}, {Column: "id"}},
+	} {
+		if err = checkShardSorts[testItem](db, sorts); err != nil {
+			t.Errorf("checkShardSorts(%v) = %v", sorts, err)
+		}
+	}
+	// 单个分片由mysql排序，不限制
+	if _, err = PaginateShards[testItem](dbs[:1], &Params{Size: 1, Sorts: []Sort{{Column: "name"}}}); err != nil {
+		t.Errorf("PaginateShards on one shard sorted by name: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 订阅连接的健康检查间隔，超过两个间隔没有任何消息视为连接失效
+const pubSubHealthCheck = 30 * time.Second
+
+// Publish 发布消息，非[]byte及string类型的消息json序列化，返回收到消息的订阅者数量
+// channel与key一样会加上前缀及KeyName
+func (p *DaoRedisEx) Publish(channel string, message interface{}) (int64, error) {
+	var payload interface{}
+	switch v := message.(type) {
+	case []byte, string:
+		payload = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return 0, err
+		}
+		payload = b
+	}
+	return redis.Int64(p.do("PUBLISH", p.getKey(channel), payload))
+}
+
+// Subscribe 订阅channel并阻塞接收消息，直到ctx取消或连接出错
+// 订阅成功后调用onReady(可为nil)；出错返回后由调用方决定是否重新订阅。
+func (p *DaoRedisEx) Subscribe(ctx context.Context, onReady func(), onMessage func(channel string, data []byte), channels ...string) error {
+	conn := getRedisPool().Get()
+	defer conn.Close()
+	psc := redis.PubSubConn{Conn: conn}
+
+	keys := make([]interface{}, 0, len(channels))
+	names := make(map[string]string, len(channels))
+	for _, channel := range channels {
+		key := p.getKey(channel)
+		keys = append(keys, key)
+		names[key] = channel
+	}
+	if err := psc.Subscribe(keys...); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for {
+			switch v := psc.ReceiveWithTimeout(2 * pubSubHealthCheck).(type) {
+			case error:
+				done <- v
+				return
+			case redis.Message:
+				onMessage(names[v.Channel], v.Data)
+			case redis.Subscription:
+				if v.Kind == "subscribe" && v.Count == len(keys) && onReady != nil {
+					onReady()
+				}
+				if v.Count == 0 {
+					done <- nil
+					return
+				}
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pubSubHealthCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := psc.Ping(""); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			if err := psc.Unsubscribe(); err != nil {
+				return err
+			}
+			return <-done
+		case err := <-done:
+			return err
+		}
+	}
+}
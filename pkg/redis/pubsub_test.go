@@ -0,0 +1,257 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"goframe/pkg/confer"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePubSub 只实现 SUBSCRIBE/UNSUBSCRIBE/PUBLISH/PING 的redis服务
+type fakePubSub struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	subs  map[string]map[*fakeConn]struct{}
+	conns map[*fakeConn]struct{}
+}
+
+type fakeConn struct {
+	net.Conn
+	mu       sync.Mutex
+	channels []string
+}
+
+func (c *fakeConn) write(values ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var b strings.Builder
+	if len(values) > 1 {
+		fmt.Fprintf(&b, "*%d\r\n", len(values))
+	}
+	for _, v := range values {
+		switch v := v.(type) {
+		case int:
+			fmt.Fprintf(&b, ":%d\r\n", v)
+		case string:
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(v), v)
+		case nil:
+			b.WriteString("$-1\r\n")
+		}
+	}
+	_, _ = c.Write([]byte(b.String()))
+}
+
+func newFakePubSub(t *testing.T) *fakePubSub {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakePubSub{ln: ln, subs: make(map[string]map[*fakeConn]struct{}), conns: make(map[*fakeConn]struct{})}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c := &fakeConn{Conn: conn}
+			s.mu.Lock()
+			s.conns[c] = struct{}{}
+			s.mu.Unlock()
+			go s.serve(c)
+		}
+	}()
+	t.Cleanup(s.close)
+	return s
+}
+
+// close 断开全部连接
+func (s *fakePubSub) close() {
+	_ = s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+func (s *fakePubSub) serve(c *fakeConn) {
+	r := bufio.NewReader(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		switch strings.ToUpper(args[0]) {
+		case "SUBSCRIBE":
+			s.mu.Lock()
+			for _, channel := range args[1:] {
+				if s.subs[channel] == nil {
+					s.subs[channel] = make(map[*fakeConn]struct{})
+				}
+				s.subs[channel][c] = struct{}{}
+				c.channels = append(c.channels, channel)
+				c.write("subscribe", channel, len(c.channels))
+			}
+			s.mu.Unlock()
+		case "UNSUBSCRIBE":
+			s.mu.Lock()
+			channels := c.channels
+			c.channels = nil
+			for i, channel := range channels {
+				delete(s.subs[channel], c)
+				c.write("unsubscribe", channel, len(channels)-i-1)
+			}
+			if len(channels) == 0 {
+				c.write("unsubscribe", nil, 0)
+			}
+			s.mu.Unlock()
+		case "PUNSUBSCRIBE":
+			c.write("punsubscribe", nil, 0)
+		case "ECHO":
+			// 连接归还连接池时以ECHO确认已退出订阅状态
+			c.write(args[1])
+		case "PUBLISH":
+			s.mu.Lock()
+			for sub := range s.subs[args[1]] {
+				sub.write("message", args[1], args[2])
+			}
+			n := len(s.subs[args[1]])
+			s.mu.Unlock()
+			c.write(n)
+		case "PING":
+			c.write("pong", "")
+		}
+	}
+}
+
+// readCommand 读取客户端以数组形式发送的命令
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func initPubSubTest(t *testing.T) *fakePubSub {
+	t.Helper()
+	// 配置只加载一次，重复加载会再次处理已转换过的mysql地址
+	if confer.GetGlobalConfig() == nil {
+		file := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(file, []byte("redis:\n  prefix: test\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := confer.Init(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newFakePubSub(t)
+	InitRedis(confer.Redis{Address: s.ln.Addr().String()})
+	return s
+}
+
+type pubSubMessage struct {
+	channel string
+	data    string
+}
+
+func TestPubSub(t *testing.T) {
+	s := initPubSubTest(t)
+	dao := &DaoRedisEx{KeyName: "ws"}
+	ctx, cancel := context.WithCancel(context.Background())
+	ready := make(chan struct{})
+	messages := make(chan pubSubMessage, 10)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- dao.Subscribe(ctx, func() { close(ready) }, func(channel string, data []byte) {
+			messages <- pubSubMessage{channel, string(data)}
+		}, "room", "other")
+	}()
+	select {
+	case <-ready:
+	case err := <-errc:
+		t.Fatalf("Subscribe: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("Subscribe did not call onReady")
+	}
+	s.mu.Lock()
+	_, ok := s.subs["test:ws:room"]
+	s.mu.Unlock()
+	if !ok {
+		t.Error("subscribed channel is not prefixed with redis.prefix and KeyName")
+	}
+
+	n, err := dao.Publish("room", map[string]int{"id": 1})
+	if err != nil || n != 1 {
+		t.Fatalf("Publish = %d, %v, want 1 subscriber", n, err)
+	}
+	if n, err = dao.Publish("nobody", "x"); err != nil || n != 0 {
+		t.Errorf("Publish to channel without subscriber = %d, %v, want 0", n, err)
+	}
+	select {
+	case msg := <-messages:
+		// 回调中的channel为去掉前缀的名称
+		if msg.channel != "room" || msg.data != `{"id":1}` {
+			t.Errorf("received %+v, want room {\"id\":1}", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message is not received")
+	}
+
+	// 取消后退订并正常返回
+	cancel()
+	select {
+	case err = <-errc:
+		if err != nil {
+			t.Errorf("Subscribe after cancel = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscribe did not return after cancel")
+	}
+}
+
+func TestPubSubConnectionLost(t *testing.T) {
+	s := initPubSubTest(t)
+	dao := &DaoRedisEx{KeyName: "ws"}
+	ready := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- dao.Subscribe(context.Background(), func() { close(ready) }, func(string, []byte) {}, "room")
+	}()
+	<-ready
+	// 连接断开时返回错误，由调用方重新订阅
+	s.close()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Error("Subscribe after connection lost = nil, want error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscribe did not return after connection lost")
+	}
+}
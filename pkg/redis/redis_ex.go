@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,6 +19,7 @@ type DaoRedisEx struct {
 	Persistent       bool // 持久化key
 	ExpireSecond     int  // 默认过期时间，单实例有效
 	tempExpireSecond int  // 临时默认过期时间，单条命令有效
+	ctx              context.Context
 }
 
 type OpOptionEx func(*DaoRedisEx)
@@ -27,6 +29,13 @@ func WithExpire(expire int) OpOptionEx {
 	return func(p *DaoRedisEx) { p.tempExpireSecond = expire }
 }
 
+// WithContext 返回绑定上下文的副本，上下文取消或超时后命令立即返回
+func (p *DaoRedisEx) WithContext(ctx context.Context) *DaoRedisEx {
+	dao := *p
+	dao.ctx = ctx
+	return &dao
+}
+
 // applyOpts 应用扩展属性
 func (p *DaoRedisEx) applyOpts(opts []OpOptionEx) {
 	for _, opt := range opts {
@@ -80,6 +89,9 @@ func (p *DaoRedisEx) do(commandName string, args ...interface{}) (reply interfac
 	}
 	defer redisClient.Close()
 	defer p.resetTempExpireSecond()
+	if p.ctx != nil {
+		return redis.DoContext(redisClient, p.ctx, commandName, args...)
+	}
 	return redisClient.Do(commandName, args...)
 }
 
@@ -1048,6 +1060,17 @@ func (p *DaoRedisEx) SMembers(key string, value interface{}) (err error) {
 	return
 }
 
+// SMembersString 获取集合中的全部成员，成员按原始字符串返回
+func (p *DaoRedisEx) SMembersString(key string) (data []string, err error) {
+	key = p.getKey(key)
+	data, err = redis.Strings(p.do("SMEMBERS", key))
+	if err != nil {
+		logger.Errorf("run redis SMEMBERS command failed: error:%v,key:%s", err, key)
+		return nil, err
+	}
+	return
+}
+
 func (p *DaoRedisEx) HGetAll(key string, data interface{}) error {
 	var args []interface{}
 
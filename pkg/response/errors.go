@@ -0,0 +1,48 @@
+package response
+
+import "fmt"
+
+// CodeError 携带业务code的错误，由typed handler等统一转换为code/message/data响应
+type CodeError struct {
+	Code    int
+	Message string
+	Data    interface{}
+	Err     error
+}
+
+// NewCodeError 创建业务错误，msg为空时输出code对应的配置信息，可在包初始化时定义
+func NewCodeError(code int, msg ...string) *CodeError {
+	e := &CodeError{Code: code}
+	if len(msg) > 0 {
+		e.Message = msg[0]
+	}
+	return e
+}
+
+// WrapCodeError 包装底层错误，响应中只输出code对应的信息，底层错误用于日志
+func WrapCodeError(code int, err error) *CodeError {
+	return &CodeError{Code: code, Err: err}
+}
+
+// Msg 响应中输出的信息
+func (e *CodeError) Msg() string {
+	return getResponseMsg(e.Code, e.Message)
+}
+
+// WithData 返回设置了data的副本，包级定义的错误可安全复用
+func (e *CodeError) WithData(data interface{}) *CodeError {
+	ce := *e
+	ce.Data = data
+	return &ce
+}
+
+func (e *CodeError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("code %d: %s: %s", e.Code, e.Msg(), e.Err.Error())
+	}
+	return fmt.Sprintf("code %d: %s", e.Code, e.Msg())
+}
+
+func (e *CodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,215 @@
+package response
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"goframe/pkg/confer"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/vmihailenco/msgpack"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatMsgpack  = "msgpack"
+	FormatProtobuf = "protobuf"
+	FormatXML      = "xml"
+
+	MIMEMsgpack  = "application/msgpack"
+	MIMEProtobuf = "application/x-protobuf"
+)
+
+// formatMIMEs 各格式接受的Content-Type/Accept，第一个为响应使用的Content-Type
+var formatMIMEs = map[string][]string{
+	FormatJSON:     {gin.MIMEJSON},
+	FormatMsgpack:  {MIMEMsgpack, "application/x-msgpack"},
+	FormatProtobuf: {MIMEProtobuf, "application/protobuf"},
+	FormatXML:      {gin.MIMEXML, gin.MIMEXML2},
+}
+
+// allowedFormats 配置允许的格式，默认格式排在第一位，未配置时只允许json
+func allowedFormats() []string {
+	if confer.GetGlobalConfig() == nil {
+		return []string{FormatJSON}
+	}
+	conf := confer.GetGlobalConfig().Http
+	def := conf.ResponseDefaultFormat
+	if _, ok := formatMIMEs[def]; !ok {
+		def = FormatJSON
+	}
+	formats := []string{def}
+	for _, f := range conf.ResponseFormats {
+		if _, ok := formatMIMEs[f]; ok && f != def {
+			formats = append(formats, f)
+		}
+	}
+	return formats
+}
+
+// negotiateFormat 根据Accept选择响应格式，protobuf只用于proto.Message类型的data
+func negotiateFormat(c *gin.Context, data interface{}) string {
+	formats := allowedFormats()
+	if c.GetHeader("Accept") == "" {
+		return formats[0]
+	}
+	offers := make([]string, 0)
+	offerFormat := make(map[string]string)
+	for _, f := range formats {
+		if f == FormatProtobuf {
+			if _, ok := data.(proto.Message); !ok && data != nil {
+				continue
+			}
+		}
+		for _, m := range formatMIMEs[f] {
+			offers = append(offers, m)
+			offerFormat[m] = f
+		}
+	}
+	if f, ok := offerFormat[c.NegotiateFormat(offers...)]; ok {
+		return f
+	}
+	return formats[0]
+}
+
+// NegotiatedFormat 按Accept协商的响应格式，不区分data是否为proto.Message，用于响应缓存的key
+func NegotiatedFormat(c *gin.Context) string {
+	return negotiateFormat(c, nil)
+}
+
+// AddVary 在Vary响应头中追加请求头，已存在的不重复添加
+func AddVary(h http.Header, names ...string) {
+	existing := strings.ToLower(strings.Join(h.Values("Vary"), ","))
+	for _, name := range names {
+		found := false
+		for _, v := range strings.Split(existing, ",") {
+			found = found || strings.TrimSpace(v) == strings.ToLower(name)
+		}
+		if !found {
+			h.Add("Vary", name)
+			existing += "," + strings.ToLower(name)
+		}
+	}
+}
+
+// render 按协商的格式输出code/message/data，允许多种格式时输出Vary: Accept
+func render(c *gin.Context, status int, code int, msg string, data interface{}) {
+	if len(allowedFormats()) > 1 {
+		AddVary(c.Writer.Header(), "Accept")
+	}
+	switch negotiateFormat(c, data) {
+	case FormatMsgpack:
+		var buf bytes.Buffer
+		err := msgpack.NewEncoder(&buf).UseJSONTag(true).Encode(map[string]interface{}{
+			"code":    code,
+			"message": msg,
+			"data":    data,
+		})
+		if err != nil {
+			_ = c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Data(status, MIMEMsgpack, buf.Bytes())
+	case FormatProtobuf:
+		body, err := marshalProtoEnvelope(code, msg, data)
+		if err != nil {
+			_ = c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Data(status, MIMEProtobuf, body)
+	case FormatXML:
+		// 先编码再输出，data无法编码为xml(如嵌套map)时改为输出json，避免输出不完整的响应
+		body, err := xml.Marshal(gin.H{"code": code, "message": msg, "data": data})
+		if err != nil {
+			logger.Warnf("response xml encode error, fallback to json: %v", err)
+			c.JSON(status, gin.H{"code": code, "message": msg, "data": data})
+			return
+		}
+		c.Data(status, gin.MIMEXML+"; charset=utf-8", body)
+	default:
+		c.JSON(status, gin.H{"code": code, "message": msg, "data": data})
+	}
+}
+
+// marshalProtoEnvelope protobuf响应结构，等同于
+//
+//	message Envelope {
+//	  int32 code = 1;
+//	  string message = 2;
+//	  google.protobuf.Any data = 3;
+//	}
+func marshalProtoEnvelope(code int, msg string, data interface{}) ([]byte, error) {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(int64(code)))
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, msg)
+	if m, ok := data.(proto.Message); ok && m != nil {
+		a, err := anypb.New(m)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := proto.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, payload)
+	}
+	return b, nil
+}
+
+// ErrUnsupportedFormat 请求体格式未在配置中允许
+var ErrUnsupportedFormat = errors.New("unsupported request content type")
+
+// DecodeBody 按Content-Type解码msgpack、protobuf、xml请求体，json及表单返回false由调用方处理
+func DecodeBody(req *http.Request, obj interface{}) (bool, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	format := ""
+	for f, mimes := range formatMIMEs {
+		for _, m := range mimes {
+			if m == mediaType {
+				format = f
+			}
+		}
+	}
+	if format == "" || format == FormatJSON {
+		return false, nil
+	}
+	allowed := false
+	for _, f := range allowedFormats() {
+		allowed = allowed || f == format
+	}
+	if !allowed {
+		return true, ErrUnsupportedFormat
+	}
+	if req.Body == nil {
+		return true, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil || len(body) == 0 {
+		return true, err
+	}
+	switch format {
+	case FormatMsgpack:
+		return true, msgpack.NewDecoder(bytes.NewReader(body)).UseJSONTag(true).Decode(obj)
+	case FormatProtobuf:
+		m, ok := obj.(proto.Message)
+		if !ok {
+			return true, ErrUnsupportedFormat
+		}
+		return true, proto.Unmarshal(body, m)
+	default:
+		return true, xml.Unmarshal(body, obj)
+	}
+}
@@ -0,0 +1,66 @@
+package response
+
+import (
+	"encoding/json"
+	"goframe/pkg/confer"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func initFormatConfig(t *testing.T) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	conf := "http:\n  response-formats: [\"json\", \"xml\"]\n  response-default-format: \"json\"\n"
+	if err := os.WriteFile(file, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := confer.Init(file); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderXML(t *testing.T) {
+	initFormatConfig(t)
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		data     interface{}
+		wantType string
+	}{
+		{"scalar data", "ok", gin.MIMEXML},
+		{"nested map falls back to json", map[string]interface{}{"stats": map[string]interface{}{"count": 1}}, gin.MIMEJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.Header.Set("Accept", gin.MIMEXML)
+			render(c, http.StatusOK, 0, "ok", tt.data)
+
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Fatalf("Content-Type = %q, want %s", got, tt.wantType)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("Vary = %q, want Accept", got)
+			}
+			if tt.wantType == gin.MIMEJSON && !json.Valid(w.Body.Bytes()) {
+				t.Errorf("body is not valid json: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{"Vary": {"Accept-Encoding, accept"}}
+	AddVary(h, "Accept", "Origin", "Origin")
+	if got := strings.Join(h.Values("Vary"), ", "); got != "Accept-Encoding, accept, Origin" {
+		t.Errorf("Vary = %q", got)
+	}
+}
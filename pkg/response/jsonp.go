@@ -0,0 +1,56 @@
+package response
+
+import (
+	"encoding/json"
+	"goframe/constv"
+	"net/http"
+	"regexp"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	jsonpEnabledKey    = "goframe_jsonp_enabled"
+	jsonpCallbackParam = "callback"
+	jsonpMaxCallback   = 128
+)
+
+// callback只允许js标识符及以点分隔的属性访问，如 cb、jQuery123_456、app.handlers.cb
+var jsonpCallbackPattern = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+// JSONP 路由组开启jsonp，组内响应在带有合法callback参数时输出jsonp
+//
+//	legacy := r.Group("/legacy", response.JSONP())
+func JSONP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(jsonpEnabledKey, true)
+		c.Next()
+	}
+}
+
+// ValidJSONPCallback 校验callback名称
+func ValidJSONPCallback(callback string) bool {
+	return len(callback) <= jsonpMaxCallback && jsonpCallbackPattern.MatchString(callback)
+}
+
+// renderJSONP 非法callback返回参数错误；输出前加注释防止Rosetta Flash类攻击
+func renderJSONP(c *gin.Context, callback string, data gin.H) {
+	c.Header("X-Content-Type-Options", "nosniff")
+	if !ValidJSONPCallback(callback) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    constv.CODE_COMMON_PARAMS_INCOMPLETE,
+			"message": getResponseMsg(constv.CODE_COMMON_PARAMS_INCOMPLETE),
+			"data":    nil,
+		})
+		return
+	}
+	// json.Marshal会转义<、>、&及U+2028/U+2029，输出可安全嵌入script
+	body, err := json.Marshal(data)
+	if err != nil {
+		logger.Errorf("jsonp marshal error: %s", err.Error())
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, "application/javascript; charset=utf-8", []byte("/**/"+callback+"("+string(body)+");"))
+}
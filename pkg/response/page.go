@@ -0,0 +1,18 @@
+package response
+
+import "github.com/gin-gonic/gin"
+
+// Page 分页列表的data结构，偏移分页返回total，游标分页返回nextCursor
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      *int64 `json:"total,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	Size       int    `json:"size"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
+}
+
+// UtilResponseReturnPage 输出分页列表
+func UtilResponseReturnPage[T any](c *gin.Context, page *Page[T]) {
+	UtilResponseReturnJson(c, 0, page)
+}
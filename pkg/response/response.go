@@ -8,25 +8,27 @@ import (
 	"goframe/pkg/util"
 	"net/http"
 
-	"github.com/HughNian/nmid/pkg/logger"
-
 	"github.com/gin-gonic/gin"
 )
 
+// UtilResponseReturnJsonNoP 不支持jsonp
 func UtilResponseReturnJsonNoP(c *gin.Context, code int, model interface{}, msg ...string) {
-	UtilResponseReturnJsonWithMsg(c, code, getResponseMsg(code, msg...), model, false, true)
+	Return(c, code, model, WithMessage(getResponseMsg(code, msg...)), WithoutJSONP())
 }
 
+// UtilResponseReturnJson 路由组开启JSONP()时支持callback参数
 func UtilResponseReturnJson(c *gin.Context, code int, model interface{}, msg ...string) {
-	UtilResponseReturnJsonWithMsg(c, code, getResponseMsg(code, msg...), model, true, true)
+	Return(c, code, model, WithMessage(getResponseMsg(code, msg...)))
 }
 
+// UtilResponseReturnJsonNoPReal 不支持jsonp，code为0时不转换为1001
 func UtilResponseReturnJsonNoPReal(c *gin.Context, code int, model interface{}, msg ...string) {
-	UtilResponseReturnJsonWithMsg(c, code, getResponseMsg(code, msg...), model, false, false)
+	Return(c, code, model, WithMessage(getResponseMsg(code, msg...)), WithoutJSONP(), WithRealCode())
 }
 
+// UtilResponseReturnJsonReal code为0时不转换为1001
 func UtilResponseReturnJsonReal(c *gin.Context, code int, model interface{}, msg ...string) {
-	UtilResponseReturnJsonWithMsg(c, code, getResponseMsg(code, msg...), model, true, false)
+	Return(c, code, model, WithMessage(getResponseMsg(code, msg...)), WithRealCode())
 }
 
 func getResponseMsg(code int, msg ...string) (message string) {
@@ -39,14 +41,52 @@ func getResponseMsg(code int, msg ...string) (message string) {
 	return
 }
 
+// UtilResponseReturnJsonWithMsg 兼容旧接口，callbackFlag为true时仍需路由组开启JSONP()才会输出jsonp
 func UtilResponseReturnJsonWithMsg(c *gin.Context, code int, msg string, model interface{},
 	callbackFlag bool, unifyCode bool) {
-	if unifyCode && code == 0 {
-		code = 1001
+	opts := []Option{WithMessage(msg)}
+	if !callbackFlag {
+		opts = append(opts, WithoutJSONP())
+	}
+	if !unifyCode {
+		opts = append(opts, WithRealCode())
 	}
-	//if msg == "" {
-	//	msg = confer.ConfigCodeGetMessage(code)
-	//}
+	Return(c, code, model, opts...)
+}
+
+type options struct {
+	message  string
+	realCode bool
+	noJSONP  bool
+}
+
+type Option func(*options)
+
+// WithMessage 自定义message，默认使用code对应的配置信息
+func WithMessage(msg string) Option {
+	return func(o *options) { o.message = msg }
+}
+
+// WithRealCode code为0时不转换为1001
+func WithRealCode() Option {
+	return func(o *options) { o.realCode = true }
+}
+
+// WithoutJSONP 即使路由组开启了JSONP也不输出jsonp
+func WithoutJSONP() Option {
+	return func(o *options) { o.noJSONP = true }
+}
+
+// Return 输出code/message/data响应，按code设置http状态码并按Accept协商格式
+func Return(c *gin.Context, code int, model interface{}, opts ...Option) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if !o.realCode && code == 0 {
+		code = constv.CODE_COMMON_OK
+	}
+	msg := getResponseMsg(code, o.message)
 	// 放入返回的code码
 	c.Set("result_code", code)
 	// 判断是否存在error上下文
@@ -55,29 +95,21 @@ func UtilResponseReturnJsonWithMsg(c *gin.Context, code int, msg string, model i
 			msg = err.(error).Error()
 		}
 	}
-	rj := gin.H{
-		"code":    code,
-		"message": msg,
-		"data":    model,
-	}
-	var callback string
-	if callbackFlag {
-		callback = c.Query("callback")
-	}
-	if util.UtilIsEmpty(callback) {
-		// 根据code码返回不同的statusCode
-		if status, ok := statusCode[code]; ok {
-			c.JSON(status, rj)
-		} else {
-			c.JSON(http.StatusOK, rj)
+	if !o.noJSONP && c.GetBool(jsonpEnabledKey) {
+		if callback := c.Query(jsonpCallbackParam); !util.UtilIsEmpty(callback) {
+			renderJSONP(c, callback, gin.H{
+				"code":    code,
+				"message": msg,
+				"data":    model,
+			})
+			return
 		}
+	}
+	// 根据code码返回不同的statusCode
+	if status, ok := statusCode[code]; ok {
+		render(c, status, code, msg, model)
 	} else {
-		r, err := json.Marshal(rj)
-		if err != nil {
-			logger.Errorf("UtilResponseReturnJsonWithMsg json Marshal error", err)
-		} else {
-			c.String(http.StatusOK, "%s(%s)", callback, r)
-		}
+		render(c, http.StatusOK, code, msg, model)
 	}
 }
 
@@ -102,11 +134,19 @@ func utilResponseJSONMarshal(t interface{}) ([]byte, error) {
 }
 
 var statusCode = map[int]int{
-	constv.CODE_SUCCESS_OK:                http.StatusOK,
-	constv.CODE_COMMON_OK:                 http.StatusOK,
-	constv.CODE_COMMON_SERVER_BUSY:        http.StatusInternalServerError,
-	constv.CODE_COMMON_PARAMS_INCOMPLETE:  http.StatusBadRequest,
-	constv.CODE_COMMON_DATA_NOT_EXIST:     http.StatusBadRequest,
-	constv.CODE_COMMON_DATA_ALREADY_EXIST: http.StatusBadRequest,
-	constv.CODE_VICTORIA_METRICS_ERR:      http.StatusInternalServerError,
+	constv.CODE_SUCCESS_OK:                    http.StatusOK,
+	constv.CODE_COMMON_OK:                     http.StatusOK,
+	constv.CODE_COMMON_SERVER_BUSY:            http.StatusInternalServerError,
+	constv.CODE_COMMON_PARAMS_INCOMPLETE:      http.StatusBadRequest,
+	constv.CODE_COMMON_DATA_NOT_EXIST:         http.StatusBadRequest,
+	constv.CODE_COMMON_DATA_ALREADY_EXIST:     http.StatusBadRequest,
+	constv.CODE_VICTORIA_METRICS_ERR:          http.StatusInternalServerError,
+	constv.CODE_COMMON_REQUEST_TIMEOUT:        http.StatusGatewayTimeout,
+	constv.CODE_COMMON_SERVER_OVERLOAD:        http.StatusServiceUnavailable,
+	constv.CODE_COMMON_BODY_TOO_LARGE:         http.StatusRequestEntityTooLarge,
+	constv.CODE_COMMON_IDEMPOTENCY_KEY_REUSED: http.StatusUnprocessableEntity,
+	constv.CODE_COMMON_REQUEST_IN_PROGRESS:    http.StatusConflict,
+	constv.CODE_COMMON_DATA_VERSION_CONFLICT:  http.StatusConflict,
+	constv.CODE_COMMON_DB_POOL_EXHAUSTED:      http.StatusServiceUnavailable,
+	constv.CODE_COMMON_FORBIDDEN:              http.StatusForbidden,
 }
@@ -0,0 +1,268 @@
+package response
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	MIMEEventStream = "text/event-stream"
+	MIMENDJSON      = "application/x-ndjson"
+	MIMECSV         = "text/csv"
+
+	// CompressorKey 压缩中间件保存可取消压缩的writer
+	CompressorKey = "goframe_compressor"
+
+	// 导出时每输出多少行刷新一次
+	streamFlushRows = 100
+)
+
+// DisableCompression 取消本次响应的gzip压缩，需在写出响应前调用
+func DisableCompression(c *gin.Context) {
+	if v, ok := c.Get(CompressorKey); ok {
+		if w, ok := v.(interface{ DisableCompression() }); ok {
+			w.DisableCompression()
+		}
+	}
+}
+
+// streamHeader 流式响应的公共header，X-Accel-Buffering关闭nginx的代理缓冲
+func streamHeader(c *gin.Context, contentType string) {
+	h := c.Writer.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+}
+
+// Event SSE事件，Data为string或[]byte时原样输出，其它类型输出json
+type Event struct {
+	ID    string
+	Event string
+	Data  interface{}
+	Retry time.Duration
+}
+
+// SSE Server-Sent Events输出
+// 长连接路由需使用gin.Timeout覆盖全局处理超时，且受http.write-timeout限制。
+type SSE struct {
+	c         *gin.Context
+	heartbeat time.Duration
+	retry     time.Duration
+}
+
+type SSEOption func(*SSE)
+
+// WithHeartbeat Run期间按间隔发送注释行保持连接，默认15秒，0为不发送
+func WithHeartbeat(d time.Duration) SSEOption {
+	return func(s *SSE) { s.heartbeat = d }
+}
+
+// WithRetry 客户端断线重连的等待时间
+func WithRetry(d time.Duration) SSEOption {
+	return func(s *SSE) { s.retry = d }
+}
+
+// NewSSE 输出SSE响应头，SSE不压缩
+func NewSSE(c *gin.Context, opts ...SSEOption) (*SSE, error) {
+	s := &SSE{c: c, heartbeat: 15 * time.Second}
+	for _, opt := range opts {
+		opt(s)
+	}
+	DisableCompression(c)
+	streamHeader(c, MIMEEventStream)
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	if s.retry > 0 {
+		if _, err := fmt.Fprintf(c.Writer, "retry: %d\n\n", s.retry.Milliseconds()); err != nil {
+			return nil, err
+		}
+	}
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+	return s, nil
+}
+
+// LastEventID 客户端重连时携带的最后一个事件id，EventSource无法自定义header时可使用lastEventId参数
+func (s *SSE) LastEventID() string {
+	if id := s.c.GetHeader("Last-Event-ID"); id != "" {
+		return id
+	}
+	return s.c.Query("lastEventId")
+}
+
+// Send 发送一个事件，客户端断开时返回错误
+func (s *SSE) Send(ev Event) error {
+	if err := s.c.Request.Context().Err(); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if ev.ID != "" {
+		buf.WriteString("id: " + sseLine(ev.ID) + "\n")
+	}
+	if ev.Event != "" {
+		buf.WriteString("event: " + sseLine(ev.Event) + "\n")
+	}
+	if ev.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	var data string
+	switch v := ev.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := utilResponseJSONMarshal(v)
+		if err != nil {
+			return err
+		}
+		data = strings.TrimRight(string(b), "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return s.write(buf.Bytes())
+}
+
+// Comment 发送注释行，客户端忽略，用于心跳
+func (s *SSE) Comment(text string) error {
+	return s.write([]byte(": " + sseLine(text) + "\n\n"))
+}
+
+// Run 持续发送events直到channel关闭或客户端断开，空闲时发送心跳
+func (s *SSE) Run(events <-chan Event) error {
+	var tick <-chan time.Time
+	if s.heartbeat > 0 {
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	ctx := s.c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := s.Send(ev); err != nil {
+				return err
+			}
+		case <-tick:
+			if err := s.Comment("ping"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *SSE) write(b []byte) error {
+	if _, err := s.c.Writer.Write(b); err != nil {
+		return err
+	}
+	s.c.Writer.Flush()
+	return nil
+}
+
+// sseLine id、event及注释不能包含换行
+func sseLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// NDJSON 使用gorm游标逐行查询并输出换行分隔的json，不把结果集载入内存
+// db需已指定Model或Table及查询条件；开始输出前的错误(如sql错误)调用方可正常返回错误响应，
+// 开始输出后状态码已发送，出错时只能中断输出，调用方记录日志即可。
+func NDJSON[T any](c *gin.Context, db *gorm.DB) error {
+	encoder := json.NewEncoder(c.Writer)
+	encoder.SetEscapeHTML(false)
+	return streamRows(c, db, func() error {
+		streamHeader(c, MIMENDJSON)
+		return nil
+	}, func(item *T) error {
+		return encoder.Encode(item)
+	}, nil)
+}
+
+// CSV 使用gorm游标逐行查询并以附件形式导出csv，row将一条记录转换为一行
+// 输出带UTF-8 BOM，Excel打开中文不乱码。
+func CSV[T any](c *gin.Context, db *gorm.DB, filename string, header []string, row func(*T) []string) error {
+	w := csv.NewWriter(c.Writer)
+	return streamRows(c, db, func() error {
+		streamHeader(c, MIMECSV+"; charset=utf-8")
+		c.Writer.Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(filename))
+		if _, err := c.Writer.WriteString("\xEF\xBB\xBF"); err != nil {
+			return err
+		}
+		if len(header) > 0 {
+			return w.Write(header)
+		}
+		return nil
+	}, func(item *T) error {
+		return w.Write(row(item))
+	}, func() error {
+		w.Flush()
+		return w.Error()
+	})
+}
+
+// streamRows 查询成功后调用begin输出响应头，之后逐行write，每streamFlushRows行刷新一次
+// 查询使用请求上下文，客户端断开后查询随之取消。
+func streamRows[T any](c *gin.Context, db *gorm.DB, begin func() error, write func(*T) error, flush func() error) error {
+	ctx := c.Request.Context()
+	rows, err := db.WithContext(ctx).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	c.Status(http.StatusOK)
+	if err = begin(); err != nil {
+		return err
+	}
+	doFlush := func() error {
+		if flush != nil {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	}
+	n := 0
+	for rows.Next() {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		item := new(T)
+		if err = db.ScanRows(rows, item); err != nil {
+			return err
+		}
+		if err = write(item); err != nil {
+			return err
+		}
+		if n++; n%streamFlushRows == 0 {
+			if err = doFlush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	c.Writer.WriteHeaderNow()
+	return doFlush()
+}
@@ -0,0 +1,286 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goframe/pkg/confer"
+	"goframe/pkg/mysql"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultDir = "./db/seeds"
+	commonSet  = "common"
+
+	// 行的标签，其他行通过 "$ref:标签" 引用该行的主键，"$ref:标签.列名" 引用其他列
+	refKey    = "_ref"
+	refPrefix = "$ref:"
+	refColumn = "id"
+)
+
+var (
+	// 文件名的数字序号前缀，用于控制加载顺序，如 01_user.yaml
+	orderPrefix = regexp.MustCompile(`^\d+[_-]`)
+	tableName   = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+)
+
+// fixture 一个文件的数据，表名默认为文件名(去掉序号前缀)，经过命名策略加上mysql.prefix
+type fixture struct {
+	file  string
+	table string
+	rows  []map[string]interface{}
+}
+
+// Loader 加载yaml/json数据文件，全部文件在同一事务中写入，
+// 文件按名称顺序加载，引用的行需在之前的文件或同一文件之前的行中
+//
+//	# db/seeds/dev/01_user.yaml
+//	- _ref: alice
+//	  name: Alice
+//	# db/seeds/dev/02_order.yaml
+//	- user_id: $ref:alice
+//	  amount: 100
+//
+// 文件内容也可以是 {table: 表名, rows: [...]}
+type Loader struct {
+	dao      *mysql.DaoMysql
+	truncate bool
+	refs     map[string]map[string]interface{}
+}
+
+type Option func(l *Loader)
+
+// WithDatasource 写入命名数据源
+func WithDatasource(name string) Option {
+	return func(l *Loader) {
+		l.dao = mysql.NewDaoMysql(name)
+	}
+}
+
+// WithTruncate 写入前清空文件涉及的表，TRUNCATE会隐式提交，不能在事务中使用
+func WithTruncate() Option {
+	return func(l *Loader) {
+		l.truncate = true
+	}
+}
+
+func New(opts ...Option) *Loader {
+	l := &Loader{dao: mysql.NewDaoMysql(), refs: map[string]map[string]interface{}{}}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Dir 初始数据目录
+func Dir() string {
+	if dir := confer.GetGlobalConfig().Mysql.Migrate.SeedDir; dir != "" {
+		return dir
+	}
+	return defaultDir
+}
+
+// Seed 加载common及env子目录的数据
+func Seed(ctx context.Context, env string, opts ...Option) error {
+	return New(opts...).LoadDir(ctx, filepath.Join(Dir(), commonSet), filepath.Join(Dir(), env))
+}
+
+// LoadDir 加载目录中的.yaml/.yml/.json文件，目录不存在时跳过
+func (l *Loader) LoadDir(ctx context.Context, dirs ...string) error {
+	var files []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(dir, entry.Name()))
+				}
+			}
+		}
+	}
+	return l.LoadFiles(ctx, files...)
+}
+
+// LoadFiles 按顺序加载文件
+func (l *Loader) LoadFiles(ctx context.Context, files ...string) error {
+	fixtures := make([]*fixture, 0, len(files))
+	for _, file := range files {
+		f, err := parse(file)
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, f)
+	}
+	if len(fixtures) == 0 {
+		return nil
+	}
+	orm := l.dao.GetWriteOrm()
+	if orm.DB == nil {
+		return errors.New("seed: mysql datasource is not initialized")
+	}
+	tables := make([]string, 0, len(fixtures))
+	for _, f := range fixtures {
+		f.table = orm.NamingStrategy.TableName(f.table)
+		tables = append(tables, f.table)
+	}
+	if l.truncate {
+		if err := l.truncateTables(ctx, tables); err != nil {
+			return err
+		}
+	}
+	return l.dao.WithTx(ctx, func(ctx context.Context) error {
+		for _, f := range fixtures {
+			if err := l.insert(ctx, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, mysql.WithRetries(0))
+}
+
+// Ref 已写入的带标签的行，包含自增主键，用于测试中获取数据
+func (l *Loader) Ref(label string) map[string]interface{} {
+	return l.refs[label]
+}
+
+func (l *Loader) insert(ctx context.Context, f *fixture) error {
+	tx := l.dao.Orm(ctx)
+	for i, row := range f.rows {
+		label, _ := row[refKey].(string)
+		values := make(map[string]interface{}, len(row))
+		for column, v := range row {
+			if column == refKey {
+				continue
+			}
+			value, err := l.value(v)
+			if err != nil {
+				return fmt.Errorf("seed %s row %d: %w", f.file, i+1, err)
+			}
+			values[column] = value
+		}
+		if err := tx.Table(f.table).Create(values).Error; err != nil {
+			return fmt.Errorf("seed %s row %d: %w", f.file, i+1, err)
+		}
+		if label == "" {
+			continue
+		}
+		if _, ok := l.refs[label]; ok {
+			return fmt.Errorf("seed %s row %d: duplicate ref %q", f.file, i+1, label)
+		}
+		if _, ok := values[refColumn]; !ok {
+			var id int64
+			if err := tx.Raw("SELECT LAST_INSERT_ID()").Scan(&id).Error; err != nil {
+				return err
+			}
+			values[refColumn] = id
+		}
+		l.refs[label] = values
+	}
+	return nil
+}
+
+// value 解析引用，对象及数组转为json(json类型的列)
+func (l *Loader) value(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		if !strings.HasPrefix(val, refPrefix) {
+			return val, nil
+		}
+		label, column := strings.TrimPrefix(val, refPrefix), refColumn
+		if i := strings.IndexByte(label, '.'); i >= 0 {
+			label, column = label[:i], label[i+1:]
+		}
+		row, ok := l.refs[label]
+		if !ok {
+			return nil, fmt.Errorf("unknown ref %q, referenced rows must be loaded first", label)
+		}
+		value, ok := row[column]
+		if !ok {
+			return nil, fmt.Errorf("ref %q has no column %s", label, column)
+		}
+		return value, nil
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(val)
+		return string(b), err
+	default:
+		return v, nil
+	}
+}
+
+// truncateTables 清空表并重置自增id，关闭外键检查以忽略表之间的顺序
+func (l *Loader) truncateTables(ctx context.Context, tables []string) error {
+	sqlDB, err := l.dao.GetWriteOrm().DB.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "SET FOREIGN_KEY_CHECKS = 1")
+	done := map[string]bool{}
+	for _, table := range tables {
+		if done[table] {
+			continue
+		}
+		done[table] = true
+		if _, err = conn.ExecContext(ctx, "TRUNCATE TABLE `"+table+"`"); err != nil {
+			return fmt.Errorf("seed truncate %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// parse yaml兼容json，两种格式使用同一解析
+func parse(file string) (*fixture, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("seed %s: %w", file, err)
+	}
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	f := &fixture{file: file, table: orderPrefix.ReplaceAllString(name, "")}
+	rows := doc
+	if m, ok := doc.(map[string]interface{}); ok {
+		if table, ok := m["table"].(string); ok {
+			f.table = table
+		}
+		rows = m["rows"]
+	}
+	list, ok := rows.([]interface{})
+	if !ok && rows != nil {
+		return nil, fmt.Errorf("seed %s: rows must be a list", file)
+	}
+	for i, item := range list {
+		row, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("seed %s row %d: must be a map", file, i+1)
+		}
+		f.rows = append(f.rows, row)
+	}
+	if !tableName.MatchString(f.table) {
+		return nil, fmt.Errorf("seed %s: invalid table name %q", file, f.table)
+	}
+	return f, nil
+}
@@ -0,0 +1,216 @@
+package static
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"goframe/pkg/confer"
+	"goframe/pkg/response"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var embedFS fs.FS
+
+// SetEmbedFS 注册go:embed打包的前端文件，需在RunHTTP之前调用，sub为构建目录
+//
+//	//go:embed web/dist
+//	var dist embed.FS
+//	static.SetEmbedFS(dist, "web/dist")
+func SetEmbedFS(fsys fs.FS, sub string) error {
+	if sub != "" && sub != "." {
+		var err error
+		if fsys, err = fs.Sub(fsys, sub); err != nil {
+			return err
+		}
+	}
+	embedFS = fsys
+	return nil
+}
+
+// 文件名带内容hash(如 app.3f2a9c1b.js、index-BX3kq9ab.css)时长期缓存
+var hashedName = regexp.MustCompile(`[.-][0-9a-zA-Z_]{8,}\.[a-z0-9]+$`)
+
+// 预压缩文件的后缀，按优先顺序
+var encodings = []struct {
+	name string
+	ext  string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+type server struct {
+	fsys        fs.FS
+	prefix      string
+	index       string
+	spa         bool
+	maxAge      int
+	apiPrefixes []string
+	etags       sync.Map
+}
+
+// Handler 静态文件处理，作为NoRoute使用，不影响已注册的路由：
+// 优先输出 .br/.gz 预压缩文件；SPA开启时未找到的页面路径返回index。
+func Handler(conf confer.HttpStatic) (gin.HandlerFunc, error) {
+	fsys := embedFS
+	if conf.Dir != "" {
+		if _, err := os.Stat(conf.Dir); err != nil {
+			return nil, err
+		}
+		fsys = os.DirFS(conf.Dir)
+	}
+	if fsys == nil {
+		return nil, errors.New("static: http.static.dir is empty and no embedded files registered")
+	}
+	return New(fsys, conf), nil
+}
+
+// New 使用指定的文件系统
+func New(fsys fs.FS, conf confer.HttpStatic) gin.HandlerFunc {
+	s := &server{
+		fsys:        fsys,
+		prefix:      "/" + strings.Trim(conf.Prefix, "/"),
+		index:       conf.Index,
+		spa:         conf.SPA,
+		maxAge:      conf.MaxAge,
+		apiPrefixes: conf.APIPrefixes,
+	}
+	if s.index == "" {
+		s.index = "index.html"
+	}
+	return s.serve
+}
+
+func (s *server) serve(c *gin.Context) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return
+	}
+	reqPath := c.Request.URL.Path
+	if s.prefix != "/" {
+		if reqPath != s.prefix && !strings.HasPrefix(reqPath, s.prefix+"/") {
+			return
+		}
+		reqPath = strings.TrimPrefix(reqPath, s.prefix)
+	}
+	name := strings.TrimPrefix(path.Clean("/"+reqPath), "/")
+	if name == "" {
+		name = s.index
+	}
+	if s.serveFile(c, name) {
+		return
+	}
+	if s.isPage(c, name) {
+		s.serveFile(c, s.index)
+	}
+}
+
+// isPage history路由：接受html、没有文件后缀且不是接口路径
+func (s *server) isPage(c *gin.Context, name string) bool {
+	if !s.spa || path.Ext(name) != "" {
+		return false
+	}
+	for _, prefix := range s.apiPrefixes {
+		if strings.HasPrefix(c.Request.URL.Path, prefix) {
+			return false
+		}
+	}
+	accept := c.GetHeader("Accept")
+	return accept == "" || strings.Contains(accept, "text/html") || strings.Contains(accept, "*/*")
+}
+
+// serveFile 文件不存在或是目录时返回false
+func (s *server) serveFile(c *gin.Context, name string) bool {
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil || info.IsDir() {
+		if err == nil {
+			// 目录使用其中的index
+			return s.serveFile(c, path.Join(name, s.index))
+		}
+		return false
+	}
+	// 预压缩文件，已压缩的内容不再经过gzip中间件
+	file, encoding := name, ""
+	acceptEncoding := c.GetHeader("Accept-Encoding")
+	for _, enc := range encodings {
+		if !strings.Contains(acceptEncoding, enc.name) {
+			continue
+		}
+		if vi, err := fs.Stat(s.fsys, name+enc.ext); err == nil && !vi.IsDir() {
+			file, encoding, info = name+enc.ext, enc.name, vi
+			break
+		}
+	}
+	f, err := s.fsys.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		return false
+	}
+
+	h := c.Writer.Header()
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		h.Set("Content-Type", ctype)
+	}
+	h.Set("Cache-Control", s.cacheControl(name))
+	h.Add("Vary", "Accept-Encoding")
+	if encoding != "" {
+		response.DisableCompression(c)
+		h.Set("Content-Encoding", encoding)
+	} else if c.GetHeader("Range") != "" {
+		// 分段请求按原始内容计算范围
+		response.DisableCompression(c)
+	}
+	if etag := s.etag(file, info, content); etag != "" {
+		h.Set("ETag", etag)
+	}
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), content)
+	return true
+}
+
+func (s *server) cacheControl(name string) string {
+	switch {
+	case path.Base(name) == s.index:
+		return "no-cache"
+	case hashedName.MatchString(path.Base(name)):
+		return "public, max-age=31536000, immutable"
+	case s.maxAge > 0:
+		return "public, max-age=" + strconv.Itoa(s.maxAge)
+	default:
+		return "no-cache"
+	}
+}
+
+// etag 目录文件使用修改时间及大小，嵌入文件(无修改时间)使用内容md5并缓存
+func (s *server) etag(name string, info fs.FileInfo, content io.ReadSeeker) string {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+	}
+	if v, ok := s.etags.Load(name); ok {
+		return v.(string)
+	}
+	h := md5.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return ""
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+	s.etags.Store(name, etag)
+	return etag
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Local 本地文件系统存储，多副本部署时root需为共享存储
+type Local struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+// 未配置upload.local.base-url时的下载路由
+const defaultBaseURL = "/files"
+
+// NewLocal baseURL为下载路由的路径(如/files)，同时用于生成下载地址，不能是带域名的完整地址
+func NewLocal(root string, baseURL string, secret string) (*Local, error) {
+	if root == "" {
+		root = "./uploads"
+	}
+	if secret == "" {
+		return nil, errors.New("storage: upload.sign-secret is required for local storage")
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	if !validBasePath(baseURL) {
+		return nil, fmt.Errorf("storage: upload.local.base-url must be a path such as %s, got %q", defaultBaseURL, baseURL)
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(abs, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{root: abs, baseURL: baseURL, secret: []byte(secret)}, nil
+}
+
+// validBasePath 以/开头的路径，不含域名、query及gin的路由参数
+func validBasePath(p string) bool {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.ContainsAny(p, ":*?#") {
+		return false
+	}
+	u, err := url.Parse(p)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// BasePath 下载路由的路径，ServeSigned注册在 {BasePath}/*key
+func (l *Local) BasePath() string {
+	return l.baseURL
+}
+
+func (l *Local) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put 先写临时文件再重命名，写入中途失败不会留下不完整的文件
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, contextReader{ctx: ctx, r: r}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// SignedURL 生成 {baseURL}/{key}?expires=&signature= 的下载地址，由ServeSigned校验
+func (l *Local) SignedURL(ctx context.Context, key string, expire time.Duration) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(expire).Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("signature", l.sign(key, expires))
+	return l.baseURL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+func (l *Local) sign(key string, expires string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeSigned 下载路由，校验签名及有效期后输出文件，路由需以 *key 结尾：
+//
+//	r.GET("/files/*key", local.ServeSigned())
+func (l *Local) ServeSigned() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, err := cleanKey(c.Param("key"))
+		if err != nil {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		expires := c.Query("expires")
+		unix, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().Unix() > unix ||
+			!hmac.Equal([]byte(l.sign(key, expires)), []byte(c.Query("signature"))) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		path, _ := l.path(key)
+		if _, err = os.Stat(path); err != nil {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.File(path)
+	}
+}
+
+// contextReader 请求取消后停止写入
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestLocal(t *testing.T) (*Local, *gin.Engine) {
+	t.Helper()
+	l, err := NewLocal(t.TempDir(), "/files/", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET(l.BasePath()+"/*key", l.ServeSigned())
+	return l, r
+}
+
+func TestLocalPutGet(t *testing.T) {
+	l, _ := newTestLocal(t)
+	ctx := context.Background()
+	if err := l.Put(ctx, "a/b.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := l.Get(ctx, "/a/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "hello" {
+		t.Errorf("Get = %q, want hello", data)
+	}
+	// 写入完成后不留下临时文件
+	entries, _ := os.ReadDir(filepath.Join(l.root, "a"))
+	if len(entries) != 1 {
+		t.Errorf("files in dir = %d, want 1", len(entries))
+	}
+	if err = l.Delete(ctx, "a/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = l.Get(ctx, "a/b.txt"); err != ErrNotFound {
+		t.Errorf("Get after Delete = %v, want %v", err, ErrNotFound)
+	}
+	if err = l.Put(ctx, "../escape", strings.NewReader("x"), 1, ""); err == nil {
+		t.Error("Put with .. in key: want error")
+	}
+}
+
+func TestLocalServeSigned(t *testing.T) {
+	l, r := newTestLocal(t)
+	ctx := context.Background()
+	if err := l.Put(ctx, "dir/a b.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := l.SignedURL(ctx, "dir/a b.txt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(signed, "/files/dir/a%20b.txt?") {
+		t.Fatalf("SignedURL = %s", signed)
+	}
+	expired, _ := l.SignedURL(ctx, "dir/a b.txt", -time.Minute)
+
+	u, _ := url.Parse(signed)
+	q := u.Query()
+	tamperedSig := url.Values{"expires": {q.Get("expires")}, "signature": {strings.Repeat("0", 64)}}
+	// 延长有效期后签名不匹配
+	tamperedExpires := url.Values{"expires": {"9999999999"}, "signature": {q.Get("signature")}}
+	otherKey, _ := l.SignedURL(ctx, "dir/other.txt", time.Minute)
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{"valid", signed, http.StatusOK},
+		{"expired", expired, http.StatusForbidden},
+		{"tampered signature", u.EscapedPath() + "?" + tamperedSig.Encode(), http.StatusForbidden},
+		{"tampered expires", u.EscapedPath() + "?" + tamperedExpires.Encode(), http.StatusForbidden},
+		{"signature of another key", "/files/dir/other.txt?" + u.RawQuery, http.StatusForbidden},
+		{"missing signature", u.EscapedPath(), http.StatusForbidden},
+		{"not found", otherKey, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.status {
+				t.Fatalf("GET %s status = %d, want %d", tt.url, w.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && w.Body.String() != "hello" {
+				t.Errorf("body = %q, want hello", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestNewLocalBaseURL(t *testing.T) {
+	for _, base := range []string{"http://cdn.example.com/files", "//cdn.example.com", "/files/:id", "files"} {
+		if _, err := NewLocal(t.TempDir(), base, "secret"); err == nil {
+			t.Errorf("NewLocal(base-url %q): want error", base)
+		}
+	}
+	if _, err := NewLocal(t.TempDir(), "", ""); err == nil {
+		t.Error("NewLocal without secret: want error")
+	}
+}
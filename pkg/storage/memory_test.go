@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	if err := m.Put(ctx, "/a/b.txt", strings.NewReader("hello"), -1, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	// key开头的 "/" 被去掉
+	if keys := m.Keys(); len(keys) != 1 || keys[0] != "a/b.txt" {
+		t.Errorf("Keys() = %v, want [a/b.txt]", keys)
+	}
+	rc, err := m.Get(ctx, "a/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	if string(data) != "hello" {
+		t.Errorf("Get = %q, want hello", data)
+	}
+	signed, err := m.SignedURL(ctx, "a/b.txt", time.Minute)
+	if err != nil || !strings.HasPrefix(signed, "memory://a/b.txt?expires=") {
+		t.Errorf("SignedURL = %s, %v", signed, err)
+	}
+
+	if err = m.Delete(ctx, "a/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Get(ctx, "a/b.txt"); err != ErrNotFound {
+		t.Errorf("Get after Delete = %v, want %v", err, ErrNotFound)
+	}
+	for _, key := range []string{"", "/", "a/../b", "./a"} {
+		if err = m.Put(ctx, key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Put(%q): want error", key)
+		}
+	}
+
+	// 请求取消后不再写入
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err = m.Put(cancelled, "c.txt", strings.NewReader("x"), 1, ""); err != context.Canceled {
+		t.Errorf("Put with cancelled context = %v, want %v", err, context.Canceled)
+	}
+	if len(m.Keys()) != 0 {
+		t.Errorf("Keys() = %v, want empty", m.Keys())
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goframe/pkg/confer"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage 文件存储后端，key为 "/" 分隔的相对路径
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL 带有效期的下载地址
+	SignedURL(ctx context.Context, key string, expire time.Duration) (string, error)
+}
+
+var defaultStorage Storage
+
+// Init 按配置初始化默认存储
+func Init(conf confer.Upload) (err error) {
+	defaultStorage, err = New(conf)
+	return
+}
+
+// Default 默认存储，未初始化时返回nil
+func Default() Storage {
+	return defaultStorage
+}
+
+// New 按配置创建存储，storage为 local|s3|memory
+func New(conf confer.Upload) (Storage, error) {
+	switch conf.Storage {
+	case "", "local":
+		return NewLocal(conf.Local.Root, conf.Local.BaseURL, conf.SignSecret)
+	case "s3":
+		return NewS3(conf.S3)
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("storage: unknown storage %q", conf.Storage)
+	}
+}
+
+// cleanKey 去掉开头的 "/"，拒绝包含 ".." 的key
+func cleanKey(key string) (string, error) {
+	key = strings.TrimLeft(key, "/")
+	if key == "" {
+		return "", errors.New("storage: empty key")
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == ".." || seg == "." {
+			return "", fmt.Errorf("storage: invalid key %q", key)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,130 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"goframe/constv"
+	"goframe/pkg/handler"
+	"goframe/pkg/response"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InitChunkedReq struct {
+	Name string `json:"name" binding:"required,max=255"`
+	Size int64  `json:"size" binding:"required,gt=0"`
+	MD5  string `json:"md5" binding:"omitempty,len=32,hexadecimal"`
+}
+
+type UploadIDReq struct {
+	UploadID string `uri:"id" binding:"required,len=32"`
+}
+
+type ChunksResp struct {
+	UploadID string `json:"uploadId"`
+	Chunks   []int  `json:"chunks"`
+}
+
+type URLResp struct {
+	URL string `json:"url"`
+}
+
+// RegisterRoutes 注册上传路由，鉴权中间件在路由组上设置：
+//
+//	POST /                     multipart上传，文件字段为file，可选字段md5需在file之前
+//	POST /chunked              创建分片上传
+//	GET  /chunked/:id          已上传的分片
+//	PUT  /chunked/:id/:index   上传分片，请求体为分片内容，可选请求头X-Chunk-MD5
+//	POST /chunked/:id/complete 合并分片
+//	GET  /:id/url              带有效期的下载地址
+//
+// 全局的请求体大小限制(http.max-body-bytes)及处理超时(http.handler-timeout)较小时，
+// 在路由组上使用gin.MaxBodySize(upload.max-size)及gin.Timeout覆盖；
+// http.read-timeout作用于整个连接，需足够读完最大的上传。
+func (u *Uploader) RegisterRoutes(r gin.IRoutes) {
+	r.POST("", u.HandleMultipart)
+	handler.POST(r, "/chunked", u.initChunked, handler.Summary("创建分片上传"), handler.Tags("upload"))
+	handler.GET(r, "/chunked/:id", u.chunks, handler.Summary("已上传的分片"), handler.Tags("upload"))
+	r.PUT("/chunked/:id/:index", u.HandleChunk)
+	handler.POST(r, "/chunked/:id/complete", u.complete, handler.Summary("合并分片"), handler.Tags("upload"))
+	handler.GET(r, "/:id/url", u.signedURL, handler.Summary("文件下载地址"), handler.Tags("upload"))
+}
+
+// HandleMultipart 流式读取multipart请求，不在内存或临时文件中缓存整个文件
+func (u *Uploader) HandleMultipart(c *gin.Context) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_PARAMS_INCOMPLETE, nil, err.Error())
+		return
+	}
+	expectMD5 := c.Query("md5")
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_PARAMS_INCOMPLETE, nil, "file is required")
+			return
+		}
+		if err != nil {
+			handler.RenderError(c, bodyError(err))
+			return
+		}
+		switch part.FormName() {
+		case "md5":
+			b, _ := io.ReadAll(io.LimitReader(part, 64))
+			expectMD5 = string(b)
+		case "file":
+			file, err := u.Save(c.Request.Context(), part.FileName(), part, -1, expectMD5)
+			if err != nil {
+				handler.RenderError(c, bodyError(err))
+				return
+			}
+			response.UtilResponseReturnJsonNoP(c, constv.CODE_SUCCESS_OK, file)
+			return
+		}
+		part.Close()
+	}
+}
+
+// HandleChunk 上传单个分片
+func (u *Uploader) HandleChunk(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		handler.RenderError(c, ErrInvalidChunk)
+		return
+	}
+	if err = u.PutChunk(c.Request.Context(), c.Param("id"), index, c.Request.Body, c.GetHeader("X-Chunk-MD5")); err != nil {
+		handler.RenderError(c, bodyError(err))
+		return
+	}
+	response.UtilResponseReturnJsonNoP(c, constv.CODE_SUCCESS_OK, gin.H{"index": index})
+}
+
+func (u *Uploader) initChunked(ctx context.Context, req InitChunkedReq) (*UploadFile, error) {
+	return u.InitChunked(ctx, req.Name, req.Size, req.MD5)
+}
+
+func (u *Uploader) chunks(ctx context.Context, req UploadIDReq) (ChunksResp, error) {
+	chunks, err := u.UploadedChunks(ctx, req.UploadID)
+	return ChunksResp{UploadID: req.UploadID, Chunks: chunks}, err
+}
+
+func (u *Uploader) complete(ctx context.Context, req UploadIDReq) (*UploadFile, error) {
+	return u.Complete(ctx, req.UploadID)
+}
+
+func (u *Uploader) signedURL(ctx context.Context, req UploadIDReq) (URLResp, error) {
+	url, err := u.SignedURL(ctx, req.UploadID)
+	return URLResp{URL: url}, err
+}
+
+// bodyError 请求体超过MaxBodySize时转换为CODE_COMMON_BODY_TOO_LARGE
+func bodyError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return ErrTooLarge
+	}
+	return err
+}
@@ -0,0 +1,25 @@
+package upload
+
+import (
+	"bytes"
+	_ "embed"
+	"text/template"
+)
+
+// MigrationTemplate migrate new --template使用的名称
+const MigrationTemplate = "upload"
+
+//go:embed migration.sql
+var migrationSQL string
+
+// migrationTpl 上传元数据表，表名及索引名与gorm按mysql.prefix生成的一致
+var migrationTpl = template.Must(template.New("upload").Parse(migrationSQL))
+
+// Migration 上传元数据表的迁移文件内容，prefix为数据源的mysql.prefix
+func Migration(prefix string) (string, error) {
+	var buf bytes.Buffer
+	if err := migrationTpl.Execute(&buf, prefix); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,34 @@
+-- 上传文件元数据(pkg/upload)，由 migrate new --template upload 按数据源的mysql.prefix生成
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS `{{.}}upload_file` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `upload_id` varchar(32) DEFAULT NULL,
+  `key` varchar(512) DEFAULT NULL,
+  `name` varchar(255) DEFAULT NULL,
+  `size` bigint DEFAULT NULL,
+  `content_type` varchar(128) DEFAULT NULL,
+  `md5` varchar(32) DEFAULT NULL,
+  `status` tinyint DEFAULT NULL,
+  `chunk_size` bigint DEFAULT NULL,
+  `total_chunks` bigint DEFAULT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  `updated_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_{{.}}upload_file_upload_id` (`upload_id`),
+  KEY `idx_{{.}}upload_file_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `{{.}}upload_chunk` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `upload_id` varchar(32) DEFAULT NULL,
+  `chunk_index` bigint DEFAULT NULL,
+  `size` bigint DEFAULT NULL,
+  `md5` varchar(32) DEFAULT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_upload_chunk` (`upload_id`, `chunk_index`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- +migrate Down
+DROP TABLE IF EXISTS `{{.}}upload_chunk`;
+DROP TABLE IF EXISTS `{{.}}upload_file`;
@@ -0,0 +1,38 @@
+package upload
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestMigration(t *testing.T) {
+	sql, err := Migration("svc_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sql, "{{") || strings.Contains(sql, "test_") {
+		t.Fatalf("template not rendered:\n%s", sql)
+	}
+	// 表名及索引名需与gorm按mysql.prefix解析的一致
+	naming := schema.NamingStrategy{TablePrefix: "svc_", SingularTable: true}
+	for _, model := range []interface{}{&UploadFile{}, &UploadChunk{}} {
+		sch, err := schema.Parse(model, &sync.Map{}, naming)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(sql, "CREATE TABLE IF NOT EXISTS `"+sch.Table+"`") {
+			t.Errorf("table %s not created", sch.Table)
+		}
+		if !strings.Contains(sql, "DROP TABLE IF EXISTS `"+sch.Table+"`") {
+			t.Errorf("table %s not dropped", sch.Table)
+		}
+		for _, idx := range sch.ParseIndexes() {
+			if !strings.Contains(sql, "`"+idx.Name+"`") {
+				t.Errorf("index %s of %s not created", idx.Name, sch.Table)
+			}
+		}
+	}
+}
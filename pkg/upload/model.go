@@ -0,0 +1,38 @@
+package upload
+
+import (
+	"time"
+)
+
+const (
+	StatusUploading  int8 = 0 // 分片上传中
+	StatusCompleted  int8 = 1
+	StatusAssembling int8 = 2 // 正在合并分片
+)
+
+// UploadFile 上传文件的元数据，UploadID为对外标识，
+// 表结构由 migrate new --template upload 生成的迁移文件创建
+type UploadFile struct {
+	ID          uint64    `gorm:"primaryKey" json:"-"`
+	UploadID    string    `gorm:"size:32;uniqueIndex" json:"uploadId"`
+	Key         string    `gorm:"size:512" json:"key"`
+	Name        string    `gorm:"size:255" json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `gorm:"size:128" json:"contentType"`
+	MD5         string    `gorm:"column:md5;size:32" json:"md5"`
+	Status      int8      `gorm:"index" json:"status"`
+	ChunkSize   int64     `json:"chunkSize,omitempty"`
+	TotalChunks int       `json:"totalChunks,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// UploadChunk 已上传的分片，分片内容保存在存储的 chunks/{uploadId}/{index}
+type UploadChunk struct {
+	ID        uint64 `gorm:"primaryKey"`
+	UploadID  string `gorm:"size:32;uniqueIndex:idx_upload_chunk"`
+	Index     int    `gorm:"column:chunk_index;uniqueIndex:idx_upload_chunk"`
+	Size      int64
+	MD5       string `gorm:"column:md5;size:32"`
+	CreatedAt time.Time
+}
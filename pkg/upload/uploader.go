@@ -0,0 +1,417 @@
+package upload
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"goframe/constv"
+	"goframe/pkg/confer"
+	"goframe/pkg/mysql"
+	"goframe/pkg/response"
+	"goframe/pkg/storage"
+	"hash"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultChunkSize  = 5 << 20
+	defaultSignExpire = 3600
+	sniffLen          = 512
+)
+
+var (
+	ErrTooLarge         = response.NewCodeError(constv.CODE_COMMON_BODY_TOO_LARGE)
+	ErrTypeNotAllowed   = response.NewCodeError(constv.CODE_COMMON_PARAMS_INCOMPLETE, "file type not allowed")
+	ErrChecksumMismatch = response.NewCodeError(constv.CODE_COMMON_PARAMS_INCOMPLETE, "checksum mismatch")
+	ErrInvalidChunk     = response.NewCodeError(constv.CODE_COMMON_PARAMS_INCOMPLETE, "invalid chunk")
+	ErrIncomplete       = response.NewCodeError(constv.CODE_COMMON_PARAMS_INCOMPLETE, "upload is incomplete")
+	ErrNotFound         = response.NewCodeError(constv.CODE_COMMON_DATA_NOT_EXIST)
+	ErrInProgress       = response.NewCodeError(constv.CODE_COMMON_REQUEST_IN_PROGRESS)
+)
+
+// Uploader 文件上传，文件内容写入Storage，元数据保存在mysql
+type Uploader struct {
+	store        storage.Storage
+	db           *gorm.DB
+	maxSize      int64
+	allowedTypes []string
+	chunkSize    int64
+	signExpire   time.Duration
+}
+
+type Option func(*Uploader)
+
+// WithStorage 指定存储，默认使用storage.Default()
+func WithStorage(s storage.Storage) Option {
+	return func(u *Uploader) { u.store = s }
+}
+
+// WithDB 指定数据库，默认使用写库
+func WithDB(db *gorm.DB) Option {
+	return func(u *Uploader) { u.db = db }
+}
+
+// WithMaxSize 单个文件的最大字节数，覆盖upload.max-size
+func WithMaxSize(n int64) Option {
+	return func(u *Uploader) { u.maxSize = n }
+}
+
+// WithAllowedTypes 允许的文件类型，支持 "image/*"，覆盖upload.allowed-types
+func WithAllowedTypes(types ...string) Option {
+	return func(u *Uploader) { u.allowedTypes = types }
+}
+
+func NewUploader(opts ...Option) *Uploader {
+	u := &Uploader{store: storage.Default(), chunkSize: defaultChunkSize, signExpire: defaultSignExpire * time.Second}
+	if conf := confer.GetGlobalConfig(); conf != nil {
+		u.maxSize = conf.Upload.MaxSize
+		u.allowedTypes = conf.Upload.AllowedTypes
+		if conf.Upload.ChunkSize > 0 {
+			u.chunkSize = conf.Upload.ChunkSize
+		}
+		if conf.Upload.SignExpire > 0 {
+			u.signExpire = time.Duration(conf.Upload.SignExpire) * time.Second
+		}
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+func (u *Uploader) orm(ctx context.Context) *gorm.DB {
+	db := u.db
+	if db == nil {
+		db = mysql.NewDaoMysql().GetWriteOrm().DB
+	}
+	return db.WithContext(ctx)
+}
+
+// Save 流式保存一个完整文件，size未知时传-1，expectMD5不为空时校验
+func (u *Uploader) Save(ctx context.Context, name string, r io.Reader, size int64, expectMD5 string) (*UploadFile, error) {
+	if u.maxSize > 0 && size > u.maxSize {
+		return nil, ErrTooLarge
+	}
+	contentType, body, err := u.sniff(r)
+	if err != nil {
+		return nil, err
+	}
+	file := &UploadFile{UploadID: newID(), Name: cleanName(name), ContentType: contentType, Status: StatusCompleted}
+	file.Key = objectKey(file.UploadID, file.Name)
+	hr := newHashReader(body, u.maxSize)
+	if err = u.store.Put(ctx, file.Key, hr, size, contentType); err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return nil, ErrTooLarge
+		}
+		return nil, err
+	}
+	file.Size, file.MD5 = hr.n, hr.sum()
+	if expectMD5 != "" && !strings.EqualFold(expectMD5, file.MD5) {
+		_ = u.store.Delete(ctx, file.Key)
+		return nil, ErrChecksumMismatch
+	}
+	if err = u.orm(ctx).Create(file).Error; err != nil {
+		_ = u.store.Delete(ctx, file.Key)
+		return nil, err
+	}
+	return file, nil
+}
+
+// InitChunked 创建分片上传，客户端按返回的chunkSize切分文件，分片可乱序、重复及断点续传
+func (u *Uploader) InitChunked(ctx context.Context, name string, size int64, md5 string) (*UploadFile, error) {
+	if size <= 0 {
+		return nil, ErrInvalidChunk
+	}
+	if u.maxSize > 0 && size > u.maxSize {
+		return nil, ErrTooLarge
+	}
+	file := &UploadFile{
+		UploadID:    newID(),
+		Name:        cleanName(name),
+		Size:        size,
+		MD5:         strings.ToLower(md5),
+		Status:      StatusUploading,
+		ChunkSize:   u.chunkSize,
+		TotalChunks: int((size + u.chunkSize - 1) / u.chunkSize),
+	}
+	file.Key = objectKey(file.UploadID, file.Name)
+	if err := u.orm(ctx).Create(file).Error; err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// PutChunk 保存一个分片，除最后一个分片外大小必须等于chunkSize，第一个分片用于识别文件类型
+func (u *Uploader) PutChunk(ctx context.Context, uploadID string, index int, r io.Reader, chunkMD5 string) error {
+	file, err := u.find(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	if file.Status != StatusUploading {
+		return ErrInProgress
+	}
+	if index < 0 || index >= file.TotalChunks {
+		return ErrInvalidChunk
+	}
+	expect := file.ChunkSize
+	if index == file.TotalChunks-1 {
+		expect = file.Size - file.ChunkSize*int64(file.TotalChunks-1)
+	}
+	if index == 0 {
+		var contentType string
+		if contentType, r, err = u.sniff(r); err != nil {
+			return err
+		}
+		if err = u.orm(ctx).Model(file).Update("content_type", contentType).Error; err != nil {
+			return err
+		}
+	}
+	hr := newHashReader(r, expect)
+	key := chunkKey(uploadID, index)
+	if err = u.store.Put(ctx, key, hr, expect, "application/octet-stream"); err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return ErrInvalidChunk
+		}
+		return err
+	}
+	if hr.n != expect || (chunkMD5 != "" && !strings.EqualFold(chunkMD5, hr.sum())) {
+		_ = u.store.Delete(ctx, key)
+		return ErrChecksumMismatch.WithData(map[string]interface{}{"index": index})
+	}
+	chunk := &UploadChunk{UploadID: uploadID, Index: index, Size: hr.n, MD5: hr.sum()}
+	return u.orm(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "upload_id"}, {Name: "chunk_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"size", "md5"}),
+	}).Create(chunk).Error
+}
+
+// UploadedChunks 已上传的分片序号，用于断点续传
+func (u *Uploader) UploadedChunks(ctx context.Context, uploadID string) ([]int, error) {
+	if _, err := u.find(ctx, uploadID); err != nil {
+		return nil, err
+	}
+	indexes := make([]int, 0)
+	err := u.orm(ctx).Model(&UploadChunk{}).Where("upload_id = ?", uploadID).
+		Order("chunk_index").Pluck("chunk_index", &indexes).Error
+	return indexes, err
+}
+
+// Complete 按序合并分片为最终文件并校验md5，合并完成后删除分片
+func (u *Uploader) Complete(ctx context.Context, uploadID string) (*UploadFile, error) {
+	file, err := u.find(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if file.Status == StatusCompleted {
+		return file, nil
+	}
+	var count int64
+	if err = u.orm(ctx).Model(&UploadChunk{}).Where("upload_id = ?", uploadID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if int(count) != file.TotalChunks {
+		return nil, ErrIncomplete
+	}
+	// 状态切换为合并中，防止并发合并
+	res := u.orm(ctx).Model(&UploadFile{}).Where("id = ? AND status = ?", file.ID, StatusUploading).
+		Update("status", StatusAssembling)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInProgress
+	}
+	sum, err := u.assemble(ctx, file)
+	if err != nil {
+		u.orm(context.Background()).Model(&UploadFile{}).Where("id = ?", file.ID).Update("status", StatusUploading)
+		return nil, err
+	}
+	if err = u.orm(ctx).Model(file).Updates(map[string]interface{}{"status": StatusCompleted, "md5": sum}).Error; err != nil {
+		return nil, err
+	}
+	for i := 0; i < file.TotalChunks; i++ {
+		_ = u.store.Delete(ctx, chunkKey(uploadID, i))
+	}
+	u.orm(ctx).Where("upload_id = ?", uploadID).Delete(&UploadChunk{})
+	return file, nil
+}
+
+func (u *Uploader) assemble(ctx context.Context, file *UploadFile) (string, error) {
+	keys := make([]string, 0, file.TotalChunks)
+	for i := 0; i < file.TotalChunks; i++ {
+		keys = append(keys, chunkKey(file.UploadID, i))
+	}
+	cr := &chunkReader{ctx: ctx, store: u.store, keys: keys}
+	defer cr.Close()
+	hr := newHashReader(cr, file.Size)
+	if err := u.store.Put(ctx, file.Key, hr, file.Size, file.ContentType); err != nil {
+		return "", err
+	}
+	sum := hr.sum()
+	if hr.n != file.Size || (file.MD5 != "" && file.MD5 != sum) {
+		_ = u.store.Delete(ctx, file.Key)
+		return "", ErrChecksumMismatch
+	}
+	return sum, nil
+}
+
+// SignedURL 已完成文件的下载地址，有效期为upload.sign-expire
+func (u *Uploader) SignedURL(ctx context.Context, uploadID string) (string, error) {
+	file, err := u.find(ctx, uploadID)
+	if err != nil {
+		return "", err
+	}
+	if file.Status != StatusCompleted {
+		return "", ErrIncomplete
+	}
+	return u.store.SignedURL(ctx, file.Key, u.signExpire)
+}
+
+// Find 查询文件元数据
+func (u *Uploader) Find(ctx context.Context, uploadID string) (*UploadFile, error) {
+	return u.find(ctx, uploadID)
+}
+
+func (u *Uploader) find(ctx context.Context, uploadID string) (*UploadFile, error) {
+	file := &UploadFile{}
+	err := u.orm(ctx).Where("upload_id = ?", uploadID).Take(file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+// sniff 按内容识别文件类型并校验，返回的reader包含已读取的内容
+func (u *Uploader) sniff(r io.Reader) (string, io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return "", nil, err
+	}
+	contentType := http.DetectContentType(head)
+	if !typeAllowed(u.allowedTypes, contentType) {
+		return "", nil, ErrTypeNotAllowed.WithData(map[string]string{"contentType": contentType})
+	}
+	return contentType, br, nil
+}
+
+func typeAllowed(allowed []string, contentType string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	for _, t := range allowed {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// hashReader 读取的同时计算md5及长度，超过limit时返回ErrTooLarge
+type hashReader struct {
+	r     io.Reader
+	h     hash.Hash
+	n     int64
+	limit int64
+}
+
+func newHashReader(r io.Reader, limit int64) *hashReader {
+	return &hashReader{r: r, h: md5.New(), limit: limit}
+}
+
+func (r *hashReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	r.n += int64(n)
+	if r.limit > 0 && r.n > r.limit {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+func (r *hashReader) sum() string {
+	return hex.EncodeToString(r.h.Sum(nil))
+}
+
+// chunkReader 依次读取各分片
+type chunkReader struct {
+	ctx   context.Context
+	store storage.Storage
+	keys  []string
+	cur   io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			rc, err := r.store.Get(r.ctx, r.keys[0])
+			if err != nil {
+				return 0, fmt.Errorf("read chunk %s: %w", r.keys[0], err)
+			}
+			r.cur, r.keys = rc, r.keys[1:]
+		}
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			r.cur.Close()
+			r.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.cur != nil {
+		return r.cur.Close()
+	}
+	return nil
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// cleanName 只保留文件名部分
+func cleanName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	if len(name) > 255 {
+		name = name[len(name)-255:]
+	}
+	return name
+}
+
+// objectKey files/2006/01/02/{uploadId}{ext}，不使用原始文件名避免特殊字符
+func objectKey(uploadID string, name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if len(ext) > 10 || strings.ContainsAny(ext, " ?#%&") {
+		ext = ""
+	}
+	return "files/" + time.Now().Format("2006/01/02") + "/" + uploadID + ext
+}
+
+func chunkKey(uploadID string, index int) string {
+	return fmt.Sprintf("chunks/%s/%d", uploadID, index)
+}
@@ -0,0 +1,115 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"goframe/pkg/storage"
+	"io"
+	"strings"
+	"testing"
+)
+
+// putChunks 按给定顺序写入分片，返回完整内容
+func putChunks(t *testing.T, store storage.Storage, file *UploadFile, order []int) []byte {
+	t.Helper()
+	data := bytes.Repeat([]byte("0123456789"), int(file.Size/10)+1)[:file.Size]
+	for _, i := range order {
+		end := int64(i+1) * file.ChunkSize
+		if end > file.Size {
+			end = file.Size
+		}
+		chunk := data[int64(i)*file.ChunkSize : end]
+		if err := store.Put(context.Background(), chunkKey(file.UploadID, i), bytes.NewReader(chunk), int64(len(chunk)), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return data
+}
+
+func newChunkedFile(size int64, chunkSize int64) *UploadFile {
+	file := &UploadFile{UploadID: newID(), Name: "a.txt", Size: size, ChunkSize: chunkSize,
+		TotalChunks: int((size + chunkSize - 1) / chunkSize)}
+	file.Key = objectKey(file.UploadID, file.Name)
+	return file
+}
+
+func TestAssemble(t *testing.T) {
+	store := storage.NewMemory()
+	u := NewUploader(WithStorage(store))
+	ctx := context.Background()
+
+	// 分片乱序上传，按序号合并
+	file := newChunkedFile(25, 10)
+	data := putChunks(t, store, file, []int{2, 0, 1})
+	sum := md5.Sum(data)
+	file.MD5 = hex.EncodeToString(sum[:])
+	got, err := u.assemble(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != file.MD5 {
+		t.Errorf("assemble md5 = %s, want %s", got, file.MD5)
+	}
+	rc, err := store.Get(ctx, file.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assembled, _ := io.ReadAll(rc)
+	if !bytes.Equal(assembled, data) {
+		t.Errorf("assembled = %q, want %q", assembled, data)
+	}
+
+	// md5不一致时删除合并结果
+	file = newChunkedFile(25, 10)
+	putChunks(t, store, file, []int{1, 2, 0})
+	file.MD5 = strings.Repeat("0", 32)
+	if _, err = u.assemble(ctx, file); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("assemble with wrong md5 = %v, want %v", err, ErrChecksumMismatch)
+	}
+	if _, err = store.Get(ctx, file.Key); err != storage.ErrNotFound {
+		t.Errorf("file after checksum mismatch: Get = %v, want %v", err, storage.ErrNotFound)
+	}
+
+	// 缺少分片
+	file = newChunkedFile(25, 10)
+	putChunks(t, store, file, []int{0, 2})
+	if _, err = u.assemble(ctx, file); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("assemble with missing chunk = %v, want %v", err, storage.ErrNotFound)
+	}
+}
+
+func TestHashReader(t *testing.T) {
+	hr := newHashReader(strings.NewReader("hello"), 5)
+	if _, err := io.ReadAll(hr); err != nil {
+		t.Fatal(err)
+	}
+	if hr.n != 5 || hr.sum() != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("hashReader n = %d sum = %s", hr.n, hr.sum())
+	}
+	hr = newHashReader(strings.NewReader("hello!"), 5)
+	if _, err := io.ReadAll(hr); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("hashReader over limit = %v, want %v", err, ErrTooLarge)
+	}
+}
+
+func TestTypeAllowed(t *testing.T) {
+	tests := []struct {
+		allowed     []string
+		contentType string
+		want        bool
+	}{
+		{nil, "application/octet-stream", true},
+		{[]string{"image/*"}, "image/png", true},
+		{[]string{"image/*"}, "text/plain; charset=utf-8", false},
+		{[]string{"text/plain"}, "text/plain; charset=utf-8", true},
+		{[]string{"image/*"}, "imagex/png", false},
+	}
+	for _, tt := range tests {
+		if got := typeAllowed(tt.allowed, tt.contentType); got != tt.want {
+			t.Errorf("typeAllowed(%v, %s) = %v, want %v", tt.allowed, tt.contentType, got, tt.want)
+		}
+	}
+}
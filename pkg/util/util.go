@@ -4,7 +4,8 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
-	"io/ioutil"
+	"io"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
@@ -27,11 +28,21 @@ func MD5(s string) string {
 
 //计算文件MD5值
 func MD5File(file string) (string, error) {
-	data, err := ioutil.ReadFile(file)
+	f, err := os.Open(file)
 	if err != nil {
 		return "", err
 	}
-	return MD5Bytes(data), nil
+	defer f.Close()
+	return MD5Reader(f)
+}
+
+//计算数据流MD5值，不把数据全部读入内存
+func MD5Reader(r io.Reader) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 func ToInterfaceSlice(slice interface{}) []interface{} {
@@ -0,0 +1,237 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goframe/constv"
+	"goframe/pkg/confer"
+	"goframe/pkg/response"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+)
+
+const (
+	LangZh = "zh"
+	LangEn = "en"
+)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors 参数绑定或校验失败的全部字段错误
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+var (
+	initOnce sync.Once
+	engine   *validator.Validate
+	uni      *ut.UniversalTranslator
+)
+
+// getEngine 复用gin的validator实例，注册的规则对c.ShouldBind同样生效
+func getEngine() *validator.Validate {
+	initOnce.Do(func() {
+		engine = binding.Validator.Engine().(*validator.Validate)
+		engine.RegisterTagNameFunc(fieldName)
+		zhLocale := zh.New()
+		uni = ut.New(zhLocale, zhLocale, en.New())
+		zhTrans, _ := uni.GetTranslator(LangZh)
+		enTrans, _ := uni.GetTranslator(LangEn)
+		_ = zhTranslations.RegisterDefaultTranslations(engine, zhTrans)
+		_ = enTranslations.RegisterDefaultTranslations(engine, enTrans)
+	})
+	return engine
+}
+
+// fieldName 错误信息中使用客户端看到的字段名：json > form > uri > 结构体字段名
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// language 配置的语言，log.app.language为ch/zh时使用中文，其余使用英文
+func language() string {
+	lang := strings.ToLower(confer.GetGlobalConfig().Log.App.Language)
+	if lang == "" || lang == "ch" || strings.HasPrefix(lang, "zh") {
+		return LangZh
+	}
+	return LangEn
+}
+
+func translator() ut.Translator {
+	getEngine()
+	trans, _ := uni.GetTranslator(language())
+	return trans
+}
+
+// RegisterRule 注册自定义校验规则，messages为各语言的错误信息，{0}为字段名，如
+// RegisterRule("mobile", isMobile, map[string]string{LangZh: "{0}不是有效的手机号", LangEn: "{0} must be a valid mobile number"})
+func RegisterRule(tag string, fn validator.Func, messages map[string]string) error {
+	v := getEngine()
+	if err := v.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+	for lang, msg := range messages {
+		trans, found := uni.GetTranslator(lang)
+		if !found {
+			return fmt.Errorf("validate language not supported: %s", lang)
+		}
+		msg := msg
+		err := v.RegisterTranslation(tag, trans, func(ut ut.Translator) error {
+			return ut.Add(tag, msg, true)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T(tag, fe.Field())
+			return t
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Struct 校验结构体，失败时返回Errors
+func Struct(obj interface{}) error {
+	err := getEngine().Struct(obj)
+	if err == nil {
+		return nil
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return Errors{{Rule: "invalid", Message: err.Error()}}
+	}
+	trans := translator()
+	result := make(Errors, 0, len(verrs))
+	for _, fe := range verrs {
+		// 去掉顶层结构体名，保留嵌套路径，如 items[0].name
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		result = append(result, FieldError{Field: field, Rule: fe.Tag(), Message: fe.Translate(trans)})
+	}
+	return result
+}
+
+// Bind 绑定并校验请求参数，失败时输出CODE_COMMON_PARAMS_INCOMPLETE及字段错误列表并返回false
+//
+//	var req CreateUserReq
+//	if !validate.Bind(c, &req) {
+//		return
+//	}
+func Bind(c *gin.Context, obj interface{}) bool {
+	err := ShouldBind(c, obj)
+	if err == nil {
+		return true
+	}
+	c.Abort()
+	if errors.Is(err, ErrBodyTooLarge) {
+		response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_BODY_TOO_LARGE, nil)
+		return false
+	}
+	response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_PARAMS_INCOMPLETE, ErrorsOf(err))
+	return false
+}
+
+// ErrBodyTooLarge 请求体超过gin.MaxBodySize的限制
+var ErrBodyTooLarge = response.NewCodeError(constv.CODE_COMMON_BODY_TOO_LARGE)
+
+// bodyError 读取请求体超过限制时返回ErrBodyTooLarge，其他错误转换为字段错误
+func bodyError(rule string, err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return ErrBodyTooLarge
+	}
+	return Errors{{Rule: rule, Message: err.Error()}}
+}
+
+// ErrorsOf 将绑定或校验错误转换为字段错误列表
+func ErrorsOf(err error) Errors {
+	var errs Errors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	return Errors{{Rule: "invalid", Message: err.Error()}}
+}
+
+// ShouldBind 依次绑定uri参数、query及表单参数、请求体(json及配置允许的msgpack/protobuf/xml)，全部绑定完成后统一校验
+func ShouldBind(c *gin.Context, obj interface{}) error {
+	params := make(map[string][]string, len(c.Params))
+	for _, p := range c.Params {
+		params[p.Key] = []string{p.Value}
+	}
+	return shouldBind(c.Request, params, obj)
+}
+
+func shouldBind(req *http.Request, params map[string][]string, obj interface{}) error {
+	if len(params) > 0 {
+		if err := binding.MapFormWithTag(obj, params, "uri"); err != nil {
+			return Errors{{Rule: "uri", Message: err.Error()}}
+		}
+	}
+	// msgpack、protobuf、xml请求体
+	if handled, err := response.DecodeBody(req, obj); handled {
+		if err != nil {
+			return bodyError("body", err)
+		}
+		if err := binding.MapFormWithTag(obj, req.URL.Query(), "form"); err != nil {
+			return Errors{{Rule: "form", Message: err.Error()}}
+		}
+		return Struct(obj)
+	}
+	contentType := req.Header.Get("Content-Type")
+	if req.Method == http.MethodGet || !strings.Contains(contentType, binding.MIMEJSON) {
+		if err := req.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			return bodyError("form", err)
+		}
+		if err := binding.MapFormWithTag(obj, req.Form, "form"); err != nil {
+			return Errors{{Rule: "form", Message: err.Error()}}
+		}
+	} else {
+		if err := binding.MapFormWithTag(obj, req.URL.Query(), "form"); err != nil {
+			return Errors{{Rule: "form", Message: err.Error()}}
+		}
+		if req.Body != nil {
+			if err := json.NewDecoder(req.Body).Decode(obj); err != nil && err != io.EOF {
+				var typeErr *json.UnmarshalTypeError
+				if errors.As(err, &typeErr) {
+					return Errors{{Field: typeErr.Field, Rule: "type", Message: err.Error()}}
+				}
+				return bodyError("json", err)
+			}
+		}
+	}
+	return Struct(obj)
+}
@@ -0,0 +1,178 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// ErrSlowConsumer 连接的发送缓冲区已满
+	ErrSlowConsumer = errors.New("websocket send buffer is full")
+	// ErrConnClosed 连接已关闭
+	ErrConnClosed = errors.New("websocket connection closed")
+)
+
+// Conn 单个websocket连接，读写各由一个协程处理，发送经过缓冲channel
+type Conn struct {
+	UserID string
+
+	hub   *Hub
+	ws    *websocket.Conn
+	send  chan []byte
+	rooms map[string]struct{} // 由hub.mu保护
+
+	mu        sync.RWMutex
+	closed    bool
+	done      chan struct{}
+	closeCode int
+	closeText string
+	values    sync.Map
+}
+
+func newConn(h *Hub, ws *websocket.Conn, userID string) *Conn {
+	return &Conn{
+		UserID:    userID,
+		hub:       h,
+		ws:        ws,
+		send:      make(chan []byte, h.opts.sendBuffer),
+		rooms:     make(map[string]struct{}),
+		done:      make(chan struct{}),
+		closeCode: websocket.CloseNormalClosure,
+	}
+}
+
+// Join 加入房间
+func (c *Conn) Join(room string) {
+	c.hub.join(c, room)
+}
+
+// Leave 离开房间
+func (c *Conn) Leave(room string) {
+	c.hub.mu.Lock()
+	c.hub.leave(c, room)
+	c.hub.mu.Unlock()
+}
+
+// Set 保存连接级别的数据
+func (c *Conn) Set(key string, value interface{}) {
+	c.values.Store(key, value)
+}
+
+func (c *Conn) Get(key string) (interface{}, bool) {
+	return c.values.Load(key)
+}
+
+// Send 只向当前连接发送消息
+func (c *Conn) Send(event string, data interface{}) error {
+	payload, err := encodeMessage("", event, data)
+	if err != nil {
+		return err
+	}
+	if err = c.sendRaw(payload); errors.Is(err, ErrSlowConsumer) {
+		c.closeWith(websocket.ClosePolicyViolation, "slow consumer")
+	}
+	return err
+}
+
+// Close 关闭连接
+func (c *Conn) Close() {
+	c.closeWith(websocket.CloseNormalClosure, "")
+}
+
+// sendRaw 不阻塞发送方，缓冲区满时返回ErrSlowConsumer
+func (c *Conn) sendRaw(payload []byte) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return ErrConnClosed
+	}
+	select {
+	case c.send <- payload:
+		return nil
+	default:
+		return ErrSlowConsumer
+	}
+}
+
+// closeWith 通知写协程发送close帧后关闭连接
+func (c *Conn) closeWith(code int, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.closeCode, c.closeText = code, text
+	close(c.done)
+}
+
+func (c *Conn) readPump() {
+	defer func() {
+		c.closeWith(websocket.CloseNormalClosure, "")
+		c.hub.unregister(c)
+		if c.hub.opts.onClose != nil {
+			c.hub.opts.onClose(c)
+		}
+	}()
+	pongWait := c.hub.opts.pingInterval * 3 / 2
+	c.ws.SetReadLimit(c.hub.opts.maxMessageSize)
+	_ = c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+				logger.Errorf("websocket read error: %s", err.Error())
+			}
+			return
+		}
+		_ = c.ws.SetReadDeadline(time.Now().Add(pongWait))
+		msg := &Message{}
+		if err = json.Unmarshal(data, msg); err != nil {
+			_ = c.Send("error", map[string]string{"message": "invalid message"})
+			continue
+		}
+		if c.hub.opts.onMessage != nil {
+			c.hub.opts.onMessage(c, msg)
+		}
+	}
+}
+
+func (c *Conn) writePump() {
+	ticker := time.NewTicker(c.hub.opts.pingInterval)
+	defer func() {
+		ticker.Stop()
+		_ = c.ws.Close()
+	}()
+	writeTimeout := c.hub.opts.writeTimeout
+	for {
+		select {
+		case payload := <-c.send:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.ws.WriteMessage(websocket.TextMessage, payload); err != nil {
+				c.closeWith(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				c.closeWith(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.done:
+			c.mu.RLock()
+			code, text := c.closeCode, c.closeText
+			c.mu.RUnlock()
+			if code != websocket.CloseAbnormalClosure {
+				_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(writeTimeout))
+			}
+			return
+		}
+	}
+}
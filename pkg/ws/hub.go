@@ -0,0 +1,301 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"goframe/constv"
+	"goframe/pkg/confer"
+	"goframe/pkg/redis"
+	"goframe/pkg/response"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/HughNian/nmid/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Message 客户端与服务端之间的消息，Data为json
+type Message struct {
+	Room  string          `json:"room,omitempty"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// envelope 经redis广播的消息，Node用于忽略本节点发出的消息
+type envelope struct {
+	Node    string          `json:"node"`
+	Room    string          `json:"room"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Backplane 在各节点之间转发广播消息，*redis.DaoRedisEx 即为redis pub/sub实现
+type Backplane interface {
+	Publish(channel string, message interface{}) (int64, error)
+	Subscribe(ctx context.Context, onReady func(), onMessage func(channel string, data []byte), channels ...string) error
+}
+
+// Hub 管理一组websocket连接及房间
+// 开启redis时广播经redis pub/sub(或WithBackplane指定的实现)发送到所有pod，未开启时只在本进程内广播。
+//
+//	hub := ws.NewHub("chat", ws.WithIdentify(func(c *gin.Context) (string, error) { return c.GetString("uid"), nil }))
+//	hub.Start()
+//	r.GET("/ws", authMiddleware, hub.Handler())
+type Hub struct {
+	name     string
+	node     string
+	opts     options
+	upgrader websocket.Upgrader
+	bp       Backplane
+
+	mu    sync.RWMutex
+	conns map[*Conn]struct{}
+	rooms map[string]map[*Conn]struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewHub name用作redis频道名，同一业务在各pod上需使用相同的name
+func NewHub(name string, opts ...Option) *Hub {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	h := &Hub{
+		name:  name,
+		node:  nodeID(),
+		opts:  o,
+		conns: make(map[*Conn]struct{}),
+		rooms: make(map[string]map[*Conn]struct{}),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     o.checkOrigin,
+		},
+	}
+	if o.bp != nil {
+		h.bp = o.bp
+	} else if o.backplane && confer.GetGlobalConfig() != nil && confer.GetGlobalConfig().Redis.Enabled {
+		h.bp = &redis.DaoRedisEx{KeyName: "ws"}
+	}
+	return h
+}
+
+// Start 启动redis订阅，断线后自动重新订阅
+func (h *Hub) Start() {
+	if h.bp == nil || h.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		backoff := time.Second
+		for ctx.Err() == nil {
+			start := time.Now()
+			err := h.bp.Subscribe(ctx, nil, h.receive, h.name)
+			if ctx.Err() != nil {
+				return
+			}
+			// 正常运行过一段时间后断开的，重新从最短间隔开始重试
+			if time.Since(start) > time.Minute {
+				backoff = time.Second
+			}
+			logger.Errorf("ws hub %s subscribe error: %v", h.name, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		}
+	}()
+}
+
+// Close 停止订阅并关闭全部连接，可作为ListenHttp的关闭回调
+func (h *Hub) Close() {
+	if h.cancel != nil {
+		h.cancel()
+		h.wg.Wait()
+	}
+	h.mu.RLock()
+	conns := make([]*Conn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.RUnlock()
+	for _, c := range conns {
+		c.closeWith(websocket.CloseGoingAway, "server shutdown")
+	}
+}
+
+// Handler 升级为websocket连接，鉴权使用路由上的中间件，WithIdentify从上下文中取出用户标识
+// 连接存续期间占用一个MaxInFlight名额，连接数较多时需相应调大http.max-in-flight。
+func (h *Hub) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := h.opts.identify(c)
+		if err != nil {
+			response.UtilResponseReturnJsonNoP(c, constv.CODE_COMMON_USER_NO_LOGIN, nil)
+			return
+		}
+		response.DisableCompression(c)
+		wsConn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// Upgrade已写出错误响应
+			return
+		}
+		conn := newConn(h, wsConn, userID)
+		h.register(conn)
+		if userID != "" {
+			conn.Join(UserRoom(userID))
+		}
+		if h.opts.onConnect != nil {
+			h.opts.onConnect(conn)
+		}
+		go conn.writePump()
+		conn.readPump()
+	}
+}
+
+// UserRoom 用户所有连接自动加入的房间，用于向指定用户推送
+func UserRoom(userID string) string {
+	return "user:" + userID
+}
+
+// Broadcast 向房间内的全部连接发送消息，room为空时发送给全部连接
+func (h *Hub) Broadcast(room string, event string, data interface{}) error {
+	payload, err := encodeMessage(room, event, data)
+	if err != nil {
+		return err
+	}
+	h.deliver(room, payload)
+	if h.bp != nil {
+		env, _ := json.Marshal(envelope{Node: h.node, Room: room, Payload: payload})
+		if _, err = h.bp.Publish(h.name, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendToUser 向用户的全部连接发送消息
+func (h *Hub) SendToUser(userID string, event string, data interface{}) error {
+	return h.Broadcast(UserRoom(userID), event, data)
+}
+
+// Count 本节点的连接数
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.conns)
+}
+
+func (h *Hub) receive(_ string, data []byte) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		logger.Errorf("ws hub %s invalid message: %v", h.name, err)
+		return
+	}
+	if env.Node == h.node {
+		return
+	}
+	h.deliver(env.Room, env.Payload)
+}
+
+// deliver 发送到本节点的连接，发送缓冲区已满的连接会被断开
+func (h *Hub) deliver(room string, payload []byte) {
+	h.mu.RLock()
+	targets := make([]*Conn, 0)
+	if room == "" {
+		for c := range h.conns {
+			targets = append(targets, c)
+		}
+	} else {
+		for c := range h.rooms[room] {
+			targets = append(targets, c)
+		}
+	}
+	h.mu.RUnlock()
+	for _, c := range targets {
+		if err := c.sendRaw(payload); errors.Is(err, ErrSlowConsumer) {
+			c.closeWith(websocket.ClosePolicyViolation, "slow consumer")
+		}
+	}
+}
+
+func (h *Hub) register(c *Conn) {
+	h.mu.Lock()
+	h.conns[c] = struct{}{}
+	h.mu.Unlock()
+}
+
+func (h *Hub) unregister(c *Conn) {
+	h.mu.Lock()
+	delete(h.conns, c)
+	for room := range c.rooms {
+		h.leave(c, room)
+	}
+	h.mu.Unlock()
+}
+
+func (h *Hub) join(c *Conn, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.conns[c]; !ok {
+		return
+	}
+	members, ok := h.rooms[room]
+	if !ok {
+		members = make(map[*Conn]struct{})
+		h.rooms[room] = members
+	}
+	members[c] = struct{}{}
+	c.rooms[room] = struct{}{}
+}
+
+// leave 调用方持有写锁
+func (h *Hub) leave(c *Conn, room string) {
+	delete(c.rooms, room)
+	if members, ok := h.rooms[room]; ok {
+		delete(members, c)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+func encodeMessage(room string, event string, data interface{}) ([]byte, error) {
+	msg := Message{Room: room, Event: event}
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		msg.Data = b
+	}
+	return json.Marshal(msg)
+}
+
+// checkSameOrigin 默认只允许同源或未携带Origin的连接
+func checkSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return websocket.IsWebSocketUpgrade(r) && sameHost(origin, r.Host)
+}
+
+// nodeID 本进程的随机标识
+func nodeID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
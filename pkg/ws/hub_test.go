@@ -0,0 +1,192 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// memBackplane 进程内的Backplane，多个Hub共用一个实例模拟多个节点
+type memBackplane struct {
+	mu   sync.Mutex
+	subs map[string][]func(channel string, data []byte)
+}
+
+func newMemBackplane() *memBackplane {
+	return &memBackplane{subs: make(map[string][]func(channel string, data []byte))}
+}
+
+func (b *memBackplane) Publish(channel string, message interface{}) (int64, error) {
+	b.mu.Lock()
+	subs := append([]func(string, []byte){}, b.subs[channel]...)
+	b.mu.Unlock()
+	for _, fn := range subs {
+		fn(channel, message.([]byte))
+	}
+	return int64(len(subs)), nil
+}
+
+func (b *memBackplane) Subscribe(ctx context.Context, onReady func(), onMessage func(channel string, data []byte), channels ...string) error {
+	b.mu.Lock()
+	for _, channel := range channels {
+		b.subs[channel] = append(b.subs[channel], onMessage)
+	}
+	b.mu.Unlock()
+	if onReady != nil {
+		onReady()
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (b *memBackplane) subscribers(channel string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs[channel])
+}
+
+// testConn 不建立websocket连接，只使用发送缓冲区
+func testConn(h *Hub, userID string) *Conn {
+	c := newConn(h, nil, userID)
+	h.register(c)
+	if userID != "" {
+		c.Join(UserRoom(userID))
+	}
+	return c
+}
+
+// received 取出缓冲区中的全部消息
+func received(t *testing.T, c *Conn) []Message {
+	t.Helper()
+	var msgs []Message
+	for {
+		select {
+		case payload := <-c.send:
+			var msg Message
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				t.Fatal(err)
+			}
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func isClosed(c *Conn) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHubRooms(t *testing.T) {
+	h := NewHub("test", WithoutBackplane())
+	a, b := testConn(h, "1"), testConn(h, "2")
+	a.Join("room")
+	b.Join("room")
+	if err := h.Broadcast("room", "hello", "all"); err != nil {
+		t.Fatal(err)
+	}
+	b.Leave("room")
+	if err := h.Broadcast("room", "hello", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SendToUser("2", "direct", nil); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := received(t, a); len(msgs) != 2 || msgs[0].Room != "room" || string(msgs[1].Data) != `"a"` {
+		t.Errorf("conn a received %+v, want two room messages", msgs)
+	}
+	if msgs := received(t, b); len(msgs) != 2 || string(msgs[0].Data) != `"all"` || msgs[1].Event != "direct" {
+		t.Errorf("conn b received %+v, want one room message and one direct message", msgs)
+	}
+
+	// 断开后从全部房间移除，空房间被删除
+	h.unregister(a)
+	if h.Count() != 1 {
+		t.Errorf("Count() = %d, want 1", h.Count())
+	}
+	if _, ok := h.rooms["room"]; ok {
+		t.Error("empty room is not removed")
+	}
+	// 未注册的连接不能加入房间
+	a.Join("room")
+	if _, ok := h.rooms["room"]; ok {
+		t.Error("unregistered conn joined room")
+	}
+
+	// room为空时发送给全部连接
+	c := testConn(h, "")
+	if err := h.Broadcast("", "all", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(received(t, a)) != 0 || len(received(t, b)) != 1 || len(received(t, c)) != 1 {
+		t.Error("broadcast without room is not delivered to every registered conn")
+	}
+}
+
+func TestHubSlowConsumer(t *testing.T) {
+	h := NewHub("test", WithoutBackplane(), WithSendBuffer(1))
+	slow, fast := testConn(h, "1"), testConn(h, "2")
+	slow.Join("room")
+	fast.Join("room")
+	if err := h.Broadcast("room", "first", nil); err != nil {
+		t.Fatal(err)
+	}
+	received(t, fast)
+	// slow未读取，第二条消息时缓冲区已满
+	if err := h.Broadcast("room", "second", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !isClosed(slow) || slow.closeCode != websocket.ClosePolicyViolation {
+		t.Errorf("slow conn closed = %v code = %d, want closed with %d", isClosed(slow), slow.closeCode, websocket.ClosePolicyViolation)
+	}
+	if isClosed(fast) || len(received(t, fast)) != 1 {
+		t.Error("fast conn is affected by the slow one")
+	}
+	if err := slow.Send("third", nil); err != ErrConnClosed {
+		t.Errorf("Send on closed conn = %v, want %v", err, ErrConnClosed)
+	}
+}
+
+func TestHubBackplane(t *testing.T) {
+	bp := newMemBackplane()
+	h1 := NewHub("test", WithBackplane(bp))
+	h2 := NewHub("test", WithBackplane(bp))
+	h1.Start()
+	h2.Start()
+	defer h1.Close()
+	defer h2.Close()
+	deadline := time.Now().Add(time.Second)
+	for bp.subscribers("test") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("hubs did not subscribe")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	c1, c2 := testConn(h1, "1"), testConn(h2, "1")
+	if err := h1.SendToUser("1", "hello", nil); err != nil {
+		t.Fatal(err)
+	}
+	// 本节点直接发送，忽略经backplane返回的自身消息，不重复发送
+	if msgs := received(t, c1); len(msgs) != 1 {
+		t.Errorf("local conn received %d messages, want 1", len(msgs))
+	}
+	if msgs := received(t, c2); len(msgs) != 1 || msgs[0].Event != "hello" || msgs[0].Room != UserRoom("1") {
+		t.Errorf("remote conn received %+v, want the broadcast", msgs)
+	}
+
+	// 无法解析的消息被忽略
+	_, _ = bp.Publish("test", []byte("invalid"))
+	if len(received(t, c1)) != 0 || len(received(t, c2)) != 0 {
+		t.Error("invalid backplane message is delivered")
+	}
+}
@@ -0,0 +1,96 @@
+package ws
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type options struct {
+	identify       func(c *gin.Context) (string, error)
+	onConnect      func(conn *Conn)
+	onMessage      func(conn *Conn, msg *Message)
+	onClose        func(conn *Conn)
+	checkOrigin    func(r *http.Request) bool
+	sendBuffer     int
+	maxMessageSize int64
+	pingInterval   time.Duration
+	writeTimeout   time.Duration
+	backplane      bool
+	bp             Backplane
+}
+
+type Option func(*options)
+
+func defaultOptions() options {
+	return options{
+		identify:       func(c *gin.Context) (string, error) { return "", nil },
+		checkOrigin:    checkSameOrigin,
+		sendBuffer:     256,
+		maxMessageSize: 64 << 10,
+		pingInterval:   30 * time.Second,
+		writeTimeout:   10 * time.Second,
+		backplane:      true,
+	}
+}
+
+// WithIdentify 从鉴权中间件设置的上下文中取出用户标识，返回错误时拒绝连接
+func WithIdentify(fn func(c *gin.Context) (string, error)) Option {
+	return func(o *options) { o.identify = fn }
+}
+
+// WithOnConnect 连接建立后回调，可在此加入房间
+func WithOnConnect(fn func(conn *Conn)) Option {
+	return func(o *options) { o.onConnect = fn }
+}
+
+// WithOnMessage 收到客户端消息的回调，在连接的读协程中执行
+func WithOnMessage(fn func(conn *Conn, msg *Message)) Option {
+	return func(o *options) { o.onMessage = fn }
+}
+
+// WithOnClose 连接关闭后回调
+func WithOnClose(fn func(conn *Conn)) Option {
+	return func(o *options) { o.onClose = fn }
+}
+
+// WithCheckOrigin 校验Origin，默认只允许同源
+func WithCheckOrigin(fn func(r *http.Request) bool) Option {
+	return func(o *options) { o.checkOrigin = fn }
+}
+
+// WithSendBuffer 每个连接的发送缓冲消息数，缓冲区满时断开该连接，默认256
+func WithSendBuffer(n int) Option {
+	return func(o *options) { o.sendBuffer = n }
+}
+
+// WithMaxMessageSize 客户端消息的最大字节数，默认64KB
+func WithMaxMessageSize(n int64) Option {
+	return func(o *options) { o.maxMessageSize = n }
+}
+
+// WithPingInterval ping间隔，超过1.5倍间隔未收到pong时断开，默认30秒
+func WithPingInterval(d time.Duration) Option {
+	return func(o *options) { o.pingInterval = d }
+}
+
+// WithoutBackplane 只在本进程内广播，不使用redis
+func WithoutBackplane() Option {
+	return func(o *options) { o.backplane = false }
+}
+
+// WithBackplane 使用指定的Backplane在节点间转发广播，不使用默认的redis
+func WithBackplane(bp Backplane) Option {
+	return func(o *options) { o.bp = bp }
+}
+
+func sameHost(origin string, host string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, host)
+}
@@ -12,4 +12,12 @@ func RouteHome(parentRoute *gin.Engine) {
 
 func RouteApi(parentRoute *gin.Engine) {
 	parentRoute.GET("/healthcheck", controller.HealthCheck)
+	parentRoute.GET("/readiness", controller.Readiness)
+}
+
+// RouteDebug 运行状态，开发环境或配置开启时注册，middlewares为访问控制
+func RouteDebug(parentRoute *gin.Engine, middlewares ...gin.HandlerFunc) {
+	debug := parentRoute.Group("/debug", middlewares...)
+	debug.GET("/sql/stats", controller.SQLStats)
+	debug.DELETE("/sql/stats", controller.ResetSQLStats)
 }
@@ -22,5 +22,123 @@ func Commands() []cli.Command {
 				return
 			},
 		},
+		{
+			Name:  "openapi",
+			Usage: "导出OpenAPI 3接口文档，用于生成客户端代码",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "o",
+					Value: "./openapi.json",
+					Usage: "output file",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return operator.ExportOpenAPI(c.String("o"))
+			},
+		},
+		{
+			Name:  "migrate",
+			Usage: "数据库迁移，迁移文件目录由mysql.migrate.dir配置",
+			Subcommands: []cli.Command{
+				{
+					Name:  "status",
+					Usage: "迁移文件及执行状态",
+					Flags: []cli.Flag{datasourceFlag},
+					Action: func(c *cli.Context) error {
+						return operator.MigrateStatus(c.String("d"))
+					},
+				},
+				{
+					Name:  "up",
+					Usage: "执行未执行的迁移",
+					Flags: []cli.Flag{datasourceFlag, cli.IntFlag{Name: "n", Usage: "number of migrations, 0 for all"}},
+					Action: func(c *cli.Context) error {
+						return operator.MigrateUp(c.String("d"), c.Int("n"))
+					},
+				},
+				{
+					Name:  "down",
+					Usage: "回滚最近执行的迁移",
+					Flags: []cli.Flag{datasourceFlag, cli.IntFlag{Name: "n", Value: 1, Usage: "number of migrations"}},
+					Action: func(c *cli.Context) error {
+						return operator.MigrateDown(c.String("d"), c.Int("n"))
+					},
+				},
+				{
+					Name:  "redo",
+					Usage: "回滚并重新执行最近的迁移",
+					Flags: []cli.Flag{datasourceFlag},
+					Action: func(c *cli.Context) error {
+						return operator.MigrateRedo(c.String("d"))
+					},
+				},
+				{
+					Name:      "new",
+					Usage:     "创建迁移文件",
+					ArgsUsage: "<name>",
+					Flags: []cli.Flag{datasourceFlag,
+						cli.StringFlag{Name: "template", Usage: "copy a bundled migration: upload (tables of pkg/upload)"}},
+					Action: func(c *cli.Context) error {
+						return operator.MigrateNew(c.String("d"), c.Args().First(), c.String("template"))
+					},
+				},
+			},
+		},
+		{
+			Name:  "db",
+			Usage: "数据库工具",
+			Subcommands: []cli.Command{
+				{
+					Name:  "seed",
+					Usage: "加载初始数据，目录由mysql.migrate.seed-dir配置",
+					Flags: []cli.Flag{
+						datasourceFlag,
+						cli.StringFlag{Name: "env", Usage: "seed set, default app.env"},
+						cli.BoolFlag{Name: "truncate", Usage: "truncate tables before loading"},
+						cli.BoolFlag{Name: "force", Usage: "allow truncate outside dev"},
+					},
+					Action: func(c *cli.Context) error {
+						return operator.Seed(c.String("d"), c.String("env"), c.Bool("truncate"), c.Bool("force"))
+					},
+				},
+				{
+					Name:      "shard",
+					Usage:     "计算分片键所在的分片，不传key时列出全部分片",
+					ArgsUsage: "[key...]",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "t", Usage: "sharded table, as configured in mysql.sharding"},
+						cli.BoolFlag{Name: "string", Usage: "treat numeric keys as strings"},
+					},
+					Action: func(c *cli.Context) error {
+						return operator.Shard(c.String("t"), c.Args(), c.Bool("string"))
+					},
+				},
+			},
+		},
+		{
+			Name:  "gen",
+			Usage: "代码生成",
+			Subcommands: []cli.Command{
+				{
+					Name:  "model",
+					Usage: "根据表结构生成model及dao，重新生成只覆盖.gen.go文件",
+					Flags: []cli.Flag{
+						datasourceFlag,
+						cli.StringFlag{Name: "t", Usage: "tables separated by comma, default all tables with the prefix"},
+						cli.StringFlag{Name: "m", Value: "base", Usage: "module under ./app"},
+						cli.BoolFlag{Name: "crud", Usage: "also create crud controllers"},
+					},
+					Action: func(c *cli.Context) error {
+						return operator.GenModel(c.String("d"), c.String("t"), c.String("m"), c.Bool("crud"))
+					},
+				},
+			},
+		},
 	}
 }
+
+// datasourceFlag 数据库相关命令的数据源，为空时使用默认数据源
+var datasourceFlag = cli.StringFlag{
+	Name:  "d",
+	Usage: "mysql datasource name",
+}
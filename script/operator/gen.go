@@ -0,0 +1,55 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"goframe/pkg/confer"
+	"goframe/pkg/gen"
+	"goframe/pkg/mysql"
+	"log"
+	"strings"
+)
+
+// GenModel 读取表结构生成model、dao及可选的controller，tables为空时生成前缀开头的全部表
+func GenModel(datasource, tables, module string, crud bool) error {
+	conf := confer.GetGlobalConfig().Mysql
+	if !conf.Enabled {
+		return errors.New("gen: mysql is not enabled")
+	}
+	prefix := sourcePrefix(datasource)
+	orm := mysql.NewDaoMysql(datasource).GetWriteOrm()
+	if orm.DB == nil {
+		return errors.New("gen: mysql datasource is not initialized")
+	}
+	var names []string
+	for _, name := range strings.Split(tables, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	list, err := gen.Inspect(context.Background(), orm.DB, names, prefix)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return errors.New("gen: no table found")
+	}
+	results, err := gen.Generate(list, gen.Options{Module: module, Prefix: prefix, Datasource: datasource, CRUD: crud})
+	for _, r := range results {
+		if r.Skipped != "" {
+			log.Printf("skip %s: %s", r.File, r.Skipped)
+		} else {
+			log.Println("generated", r.File)
+		}
+	}
+	return err
+}
+
+// sourcePrefix 数据源配置的表前缀
+func sourcePrefix(datasource string) string {
+	conf := confer.GetGlobalConfig().Mysql
+	if datasource != "" && datasource != mysql.DefaultDatasource {
+		return conf.Datasources[datasource].Prefix
+	}
+	return conf.Prefix
+}
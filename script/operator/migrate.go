@@ -0,0 +1,102 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goframe/pkg/migration"
+	"goframe/pkg/upload"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// MigrateStatus 输出迁移文件及执行时间
+func MigrateStatus(datasource string) error {
+	m, err := migration.New(datasource)
+	if err != nil {
+		return err
+	}
+	records, err := m.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tAPPLIED AT")
+	for _, r := range records {
+		applied := "pending"
+		if r.AppliedAt != nil {
+			applied = r.AppliedAt.Format(time.RFC3339)
+		}
+		if r.Missing {
+			applied += " (file missing)"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", r.ID, applied)
+	}
+	return w.Flush()
+}
+
+// MigrateUp 执行未执行的迁移，n为0时全部执行
+func MigrateUp(datasource string, n int) error {
+	m, err := migration.New(datasource)
+	if err != nil {
+		return err
+	}
+	applied, err := m.Up(context.Background(), n)
+	log.Printf("%d migrations applied", applied)
+	return err
+}
+
+// MigrateDown 回滚最近的n个迁移
+func MigrateDown(datasource string, n int) error {
+	if n <= 0 {
+		return errors.New("n must be greater than 0")
+	}
+	m, err := migration.New(datasource)
+	if err != nil {
+		return err
+	}
+	rolledBack, err := m.Down(context.Background(), n)
+	log.Printf("%d migrations rolled back", rolledBack)
+	return err
+}
+
+// MigrateRedo 回滚并重新执行最近的一个迁移
+func MigrateRedo(datasource string) error {
+	m, err := migration.New(datasource)
+	if err != nil {
+		return err
+	}
+	applied, err := m.Redo(context.Background())
+	log.Printf("%d migrations redone", applied)
+	return err
+}
+
+// MigrateNew 创建迁移文件，template不为空时使用框架提供的迁移模板，如 upload
+func MigrateNew(datasource, name, template string) error {
+	var (
+		file string
+		err  error
+	)
+	switch template {
+	case "":
+		file, err = migration.Create(datasource, name)
+	case upload.MigrationTemplate:
+		if name == "" {
+			name = "create_upload_tables"
+		}
+		var content string
+		if content, err = upload.Migration(sourcePrefix(datasource)); err != nil {
+			return err
+		}
+		file, err = migration.CreateFrom(datasource, name, content)
+	default:
+		return fmt.Errorf("unknown migration template %q", template)
+	}
+	if err != nil {
+		return err
+	}
+	log.Println("migration created:", file)
+	return nil
+}
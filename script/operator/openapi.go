@@ -0,0 +1,23 @@
+package operator
+
+import (
+	"encoding/json"
+	"goframe/pkg/openapi"
+	"goframe/server"
+	"log"
+	"os"
+)
+
+// ExportOpenAPI 注册全部路由后生成OpenAPI文档并写入文件
+func ExportOpenAPI(output string) error {
+	doc := openapi.Build(server.NewEngine().Routes())
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(output, data, 0644); err != nil {
+		return err
+	}
+	log.Println("openapi document exported to", output)
+	return nil
+}
@@ -0,0 +1,31 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"goframe/pkg/confer"
+	"goframe/pkg/seed"
+	"log"
+)
+
+// Seed 加载初始数据，非开发环境清空表需要force
+func Seed(datasource, env string, truncate, force bool) error {
+	if env == "" {
+		env = confer.ConfigEnvGet()
+	}
+	if truncate && !force && !confer.ConfigEnvIsDev() {
+		return fmt.Errorf("refuse to truncate tables in env %s, use --force", confer.ConfigEnvGet())
+	}
+	var opts []seed.Option
+	if datasource != "" {
+		opts = append(opts, seed.WithDatasource(datasource))
+	}
+	if truncate {
+		opts = append(opts, seed.WithTruncate())
+	}
+	if err := seed.Seed(context.Background(), env, opts...); err != nil {
+		return err
+	}
+	log.Printf("seed %s loaded from %s", env, seed.Dir())
+	return nil
+}
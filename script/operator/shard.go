@@ -0,0 +1,57 @@
+package operator
+
+import (
+	"errors"
+	"fmt"
+	"goframe/pkg/confer"
+	"goframe/pkg/mysql"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+// Shard 输出分片键所在的分片，不传key时列出全部分片；
+// 数字形式的key按整数计算，分片键为字符串列时使用asString
+func Shard(table string, keys []string, asString bool) error {
+	if table == "" {
+		return errors.New("shard: table is required")
+	}
+	rule, ok := confer.GetGlobalConfig().Mysql.Sharding[table]
+	if !ok {
+		return fmt.Errorf("shard: table %s is not sharded", table)
+	}
+	s, err := mysql.NewSharder(table, rule)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if len(keys) == 0 {
+		fmt.Fprintln(w, "SHARD\tDATASOURCE\tTABLE")
+		for _, shard := range s.Shards() {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", shard.Index, shardDatasource(shard), shard.Table)
+		}
+		return w.Flush()
+	}
+	fmt.Fprintf(w, "%s\tSHARD\tDATASOURCE\tTABLE\n", s.Key())
+	for _, key := range keys {
+		var value interface{} = key
+		if n, err := strconv.ParseInt(key, 10, 64); err == nil && !asString {
+			value = n
+		} else if u, err := strconv.ParseUint(key, 10, 64); err == nil && !asString {
+			value = u
+		}
+		shard, err := s.Locate(value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", key, shard.Index, shardDatasource(shard), shard.Table)
+	}
+	return w.Flush()
+}
+
+func shardDatasource(shard mysql.Shard) string {
+	if shard.Datasource == "" {
+		return "(dao datasource)"
+	}
+	return shard.Datasource
+}
@@ -1,13 +1,18 @@
 package server
 
 import (
+	"github.com/HughNian/nmid/pkg/logger"
 	"goframe/middleware"
 	"goframe/pkg/confer"
 	"goframe/pkg/gin"
+	"goframe/pkg/openapi"
+	"goframe/pkg/static"
+	"goframe/pkg/storage"
 	"goframe/route"
 	"strconv"
+	"time"
 
-	"github.com/gin-contrib/gzip"
+	ginE "github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -16,20 +21,73 @@ var httpPort int
 
 func RunHTTP() {
 	println("RunHttp Server.")
+	r := NewEngine()
+	httpPort = confer.ConfigAppGetInt("port", 80)
+	portStr := ":" + strconv.Itoa(httpPort)
+	gin.ListenHttp(portStr, r, 10)
+}
+
+// NewEngine 创建gin引擎并注册中间件及全部路由，openapi导出命令同样使用
+func NewEngine() *ginE.Engine {
 	r := gin.NewGin()
+	// 请求id，写入响应头，sql日志中带有该id
+	r.Use(gin.RequestID())
 	// 跨域
 	r.Use(middleware.Cors())
-	// gzip压缩
+	// gzip压缩，支持流式响应
 	if confer.GetGlobalConfig().Gzip.Enabled {
-		r.Use(gzip.Gzip(confer.GetGlobalConfig().Gzip.Level))
+		r.Use(gin.Gzip(confer.GetGlobalConfig().Gzip.Level))
 	}
-	httpPort = confer.ConfigAppGetInt("port", 80)
-	println("|- http start at:", httpPort)
-	portStr := ":" + strconv.Itoa(httpPort)
-	if confer.ConfigEnvIsDev() {
-		r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// 限流、请求体大小及处理超时
+	httpConf := confer.GetGlobalConfig().Http
+	r.Use(gin.MaxInFlight(httpConf.MaxInFlight))
+	r.Use(gin.MaxBodySize(httpConf.MaxBodyBytes))
+	r.Use(gin.Timeout(time.Duration(httpConf.HandlerTimeout) * time.Second))
+	// 读写分离时请求内写后读走写库
+	if hasMysqlReads(confer.GetGlobalConfig().Mysql) {
+		r.Use(gin.ReadYourWrites())
+	}
+	// 接口文档
+	if confer.ConfigEnvIsDev() || httpConf.OpenAPI {
+		r.GET(openapi.SpecPath, openapi.Handler(r))
+		r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL(openapi.SpecPath)))
+	}
+	// 本地存储的签名下载地址
+	if local, ok := storage.Default().(*storage.Local); ok {
+		r.GET(local.BasePath()+"/*key", local.ServeSigned())
+	}
+	// sql统计，非开发环境需配置令牌或允许的地址
+	if mysqlConf := confer.GetGlobalConfig().Mysql; mysqlConf.Enabled && (confer.ConfigEnvIsDev() || mysqlConf.Log.Admin) {
+		logConf := mysqlConf.Log
+		if !confer.ConfigEnvIsDev() && logConf.AdminToken == "" && len(logConf.AdminAllowIPs) == 0 {
+			logger.Error("sql stats disabled: mysql.log.admin-token or admin-allow-ips is required")
+		} else {
+			route.RouteDebug(r, gin.AdminGuard(logConf.AdminToken, logConf.AdminAllowIPs))
+		}
 	}
 	route.RouteHome(r)
 	route.RouteApi(r)
-	gin.ListenHttp(portStr, r, 10)
+	// 静态文件及单页应用，只处理未匹配的路由
+	if httpConf.Static.Enabled {
+		h, err := static.Handler(httpConf.Static)
+		if err != nil {
+			logger.Errorf("static files disabled: %s", err.Error())
+		} else {
+			r.NoRoute(h)
+		}
+	}
+	return r
+}
+
+// hasMysqlReads 任一数据源配置了读库
+func hasMysqlReads(conf confer.Mysql) bool {
+	if len(conf.Reads) > 0 {
+		return true
+	}
+	for _, source := range conf.Datasources {
+		if len(source.Reads) > 0 {
+			return true
+		}
+	}
+	return false
 }
@@ -1,18 +1,21 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"github.com/HughNian/nmid/pkg/logger"
 	"goframe/pkg/confer"
 	"goframe/pkg/initer"
-	"goframe/pkg/mysql"
+	"goframe/pkg/migration"
 	"runtime"
 	"time"
 
-	migrate "github.com/rubenv/sql-migrate"
 	"github.com/urfave/cli"
 )
 
+// configOnlyCommands 只加载配置、不初始化外部服务的命令
+var configOnlyCommands = map[string]bool{"openapi": true}
+
 func InitService(c *cli.Context) error {
 	//环境初始化
 	configRuntime()
@@ -21,12 +24,18 @@ func InitService(c *cli.Context) error {
 	if err != nil {
 		logger.Fatal(fmt.Sprintf("init ConfigAndBase err : %v", err))
 	}
+	// 只需要配置的命令(如openapi导出)不连接mysql、redis等外部服务
+	if configOnlyCommands[c.Args().First()] {
+		return nil
+	}
 	// 初始化外部依赖服务
 	err = initer.OutSideResource()
 	if err != nil {
 		logger.Fatal(fmt.Sprintf("init OutSideResource err : %v", err))
 	}
-	if !confer.ConfigEnvIsDev() && confer.GetGlobalConfig().Mysql.Enabled {
+	// 执行命令(如migrate)时不自动迁移
+	mysqlConf := confer.GetGlobalConfig().Mysql
+	if !confer.ConfigEnvIsDev() && mysqlConf.Enabled && !mysqlConf.Migrate.DisableAuto && !c.Args().Present() {
 		sqlMigrate()
 	}
 	return nil
@@ -40,21 +49,9 @@ func configRuntime() {
 	fmt.Printf("Running with %d CPUs\n", numCPU)
 }
 
+// sqlMigrate 启动时执行全部数据源的迁移，多个实例同时启动时依次执行
 func sqlMigrate() {
-	// docker 环境下，根据docker file 配置，sql文件在统计db目录下
-	migrations := &migrate.FileMigrationSource{
-		Dir: "./db",
-	}
-	Orm := mysql.NewDaoMysql().GetOrm()
-	sqlDb, err := Orm.DB.DB()
-	if err != nil {
-		logger.Errorf("sqlMigrate Orm.DB.DB() err ", err)
-		return
-	}
-	code, err := migrate.Exec(sqlDb, "mysql", migrations, migrate.Up)
-	if err != nil {
-		logger.Errorf("sqlMigrate err ", err)
-		return
+	if err := migration.AutoUp(context.Background()); err != nil {
+		logger.Errorf("sqlMigrate err: %v", err)
 	}
-	logger.Errorf("sqlMigrate code is : %d", code)
 }